@@ -0,0 +1,177 @@
+package toycache
+
+import "sync"
+
+const (
+	arcT1 = iota + 1
+	arcT2
+	arcB1
+	arcB2
+)
+
+// ARC 是自适应替换缓存（Adaptive Replacement Cache）淘汰策略。
+// T1 保存只访问过一次的 key，T2 保存访问过多次的 key，
+// B1、B2 是幽灵队列，只记录最近从 T1、T2 淘汰的 key，不保存值。
+// 被淘汰的 key 再次写入的时候命中幽灵队列，说明对应的队列太小了，
+// 据此调整 T1 的目标大小 p，所以它能在偏重时间局部性和偏重访问频率的负载之间自动切换
+type ARC struct {
+	sync.Mutex
+	capacity int
+	// p 是 T1 的目标大小
+	p int
+
+	t1, t2, b1, b2 *linkedList
+	cache          map[any]*arcEntry
+}
+
+type arcEntry struct {
+	node *node
+	list int
+}
+
+// NewARC capacity 是预期的 key 的数量，幽灵队列最多记录 capacity 个 key
+func NewARC(capacity int) *ARC {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &ARC{
+		capacity: capacity,
+		t1:       newLinkedList(),
+		t2:       newLinkedList(),
+		b1:       newLinkedList(),
+		b2:       newLinkedList(),
+		cache:    make(map[any]*arcEntry),
+	}
+}
+
+func (a *ARC) Get(key any) (AnyValue, bool) {
+	a.Lock()
+	defer a.Unlock()
+	e, ok := a.cache[key]
+	if !ok || (e.list != arcT1 && e.list != arcT2) {
+		return AnyValue{}, false
+	}
+	return AnyValue{Val: e.node.value}, true
+}
+
+// GetEliminatedKey T1 超过目标大小的时候淘汰 T1 最老的 key，否则淘汰 T2 最老的 key
+func (a *ARC) GetEliminatedKey() (any, AnyValue, bool) {
+	a.Lock()
+	defer a.Unlock()
+	var n *node
+	if a.t1.len() > 0 && (a.t1.len() > a.p || a.t2.len() == 0) {
+		n = a.t1.back()
+	} else {
+		n = a.t2.back()
+	}
+	if n == nil {
+		return nil, AnyValue{}, false
+	}
+	return n.key, AnyValue{Val: n.value}, true
+}
+
+func (a *ARC) Add(key any, args ...any) {
+	if key == nil {
+		return
+	}
+	var val any
+	if len(args) != 0 {
+		val = args[0]
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	e, ok := a.cache[key]
+	if !ok {
+		e = &arcEntry{node: &node{key: key, value: val}, list: arcT1}
+		a.cache[key] = e
+		a.t1.pushFront(e.node)
+		a.trim()
+		return
+	}
+
+	e.node.value = val
+	switch e.list {
+	case arcT1:
+		a.move(e, arcT2)
+	case arcT2:
+		a.t2.moveToFront(e.node)
+	case arcB1:
+		// 命中 B1 说明 T1 太小了
+		a.p = minInt(a.capacity, a.p+maxInt(a.b2.len()/a.b1.len(), 1))
+		a.move(e, arcT2)
+	case arcB2:
+		a.p = maxInt(0, a.p-maxInt(a.b1.len()/a.b2.len(), 1))
+		a.move(e, arcT2)
+	}
+}
+
+// Remove 被移除的 key 会进入对应的幽灵队列，key 不在 T1、T2 里的时候返回 false
+func (a *ARC) Remove(key any) bool {
+	a.Lock()
+	defer a.Unlock()
+	e, ok := a.cache[key]
+	if !ok {
+		return false
+	}
+	switch e.list {
+	case arcT1:
+		a.move(e, arcB1)
+	case arcT2:
+		a.move(e, arcB2)
+	default:
+		return false
+	}
+	e.node.value = nil
+	a.trim()
+	return true
+}
+
+func (a *ARC) list(list int) *linkedList {
+	switch list {
+	case arcT1:
+		return a.t1
+	case arcT2:
+		return a.t2
+	case arcB1:
+		return a.b1
+	default:
+		return a.b2
+	}
+}
+
+func (a *ARC) move(e *arcEntry, list int) {
+	a.list(e.list).remove(e.node)
+	a.list(list).pushFront(e.node)
+	e.list = list
+}
+
+// trim 限制幽灵队列的长度：T1 和 B1 加起来不超过 capacity，四个队列加起来不超过 2 * capacity
+func (a *ARC) trim() {
+	for a.b1.len() > 0 && a.t1.len()+a.b1.len() > a.capacity {
+		a.drop(a.b1)
+	}
+	for a.b2.len() > 0 && a.t1.len()+a.t2.len()+a.b1.len()+a.b2.len() > 2*a.capacity {
+		a.drop(a.b2)
+	}
+}
+
+func (a *ARC) drop(l *linkedList) {
+	n := l.back()
+	l.remove(n)
+	delete(a.cache, n.key)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,75 @@
+package toycache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// evictN 按照淘汰策略淘汰 n 个 key，返回被淘汰的 key
+func evictN(t *testing.T, s EliminateStrategy, n int) []any {
+	res := make([]any, 0, n)
+	for i := 0; i < n; i++ {
+		key, _, ok := s.GetEliminatedKey()
+		require.True(t, ok)
+		require.True(t, s.Remove(key))
+		res = append(res, key)
+	}
+	return res
+}
+
+func TestARC_GetEliminatedKey(t *testing.T) {
+	a := NewARC(4)
+	_, _, ok := a.GetEliminatedKey()
+	assert.False(t, ok)
+
+	a.Add(1, 1)
+	a.Add(2, 2)
+	a.Add(3, 3)
+	// 访问过两次的 key 进入 T2
+	a.Add(1, 1)
+	assert.Equal(t, []any{2, 3, 1}, evictN(t, a, 3))
+	_, ok = a.Get(1)
+	assert.False(t, ok)
+}
+
+func TestARC_GhostHit(t *testing.T) {
+	a := NewARC(4)
+	for i := 1; i <= 4; i++ {
+		a.Add(i, i)
+	}
+	a.Add(3, 3)
+	a.Add(4, 4)
+	assert.Equal(t, []any{1, 2}, evictN(t, a, 2))
+	assert.Equal(t, 0, a.p)
+
+	// 命中 B1，T1 的目标大小变大，key 直接进入 T2
+	a.Add(1, 1)
+	assert.Equal(t, 1, a.p)
+	assert.Equal(t, arcT2, a.cache[1].list)
+	a.Add(5, 5)
+	a.Add(2, 2)
+	assert.Equal(t, 2, a.p)
+
+	// T1 没有超过目标大小，淘汰 T2 最老的 key
+	a.Add(6, 6)
+	assert.Equal(t, []any{3}, evictN(t, a, 1))
+	// 命中 B2，T1 的目标大小变小
+	a.Add(3, 3)
+	assert.Equal(t, 1, a.p)
+	assert.Equal(t, arcT2, a.cache[3].list)
+	assert.Equal(t, []any{5}, evictN(t, a, 1))
+}
+
+func TestARC_Trim(t *testing.T) {
+	a := NewARC(2)
+	for i := 0; i < 10; i++ {
+		a.Add(i, i)
+		evictN(t, a, 1)
+	}
+	// T1 和 B1 加起来不超过 capacity
+	assert.Equal(t, 2, a.b1.len())
+	assert.Equal(t, 2, len(a.cache))
+	assert.False(t, a.Remove(8))
+	assert.False(t, a.Remove(100))
+}
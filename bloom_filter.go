@@ -0,0 +1,183 @@
+package toycache
+
+import (
+	"context"
+	"github.com/go-redis/redis/v9"
+	"github.com/google/uuid"
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// BloomFilter 用于判断一个 key 是否可能存在，
+// Exist 返回 false 的时候 key 一定不存在，返回 true 的时候有一定的误判率
+type BloomFilter interface {
+	Add(ctx context.Context, keys ...string) error
+	Exist(ctx context.Context, key string) (bool, error)
+	// Rebuild 使用 keys 重新构建过滤器，已经被删除的 key 可以借此清理掉
+	Rebuild(ctx context.Context, keys []string) error
+}
+
+// defaultFalsePositive 是误判率不在 (0, 1) 之间的时候使用的默认值
+const defaultFalsePositive = 0.01
+
+// bloomParams 根据预期的元素数量 n 和误判率 p 计算位数组的大小和哈希函数的个数
+func bloomParams(n uint64, p float64) (m uint64, k uint64) {
+	if n == 0 {
+		n = 1
+	}
+	if !(p > 0 && p < 1) {
+		p = defaultFalsePositive
+	}
+	m = uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	if m == 0 {
+		m = 1
+	}
+	k = uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k == 0 {
+		k = 1
+	}
+	return m, k
+}
+
+// bloomLocations 使用双重哈希计算 key 对应的 k 个位置
+func bloomLocations(key string, m, k uint64) []uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := sum&math.MaxUint32, sum>>32|1
+	res := make([]uint64, k)
+	for i := uint64(0); i < k; i++ {
+		res[i] = (h1 + i*h2) % m
+	}
+	return res
+}
+
+// LocalBloomFilter 是进程内的布隆过滤器
+type LocalBloomFilter struct {
+	mutex sync.RWMutex
+	bits  []uint64
+	m     uint64
+	k     uint64
+}
+
+// NewLocalBloomFilter n 是预期的元素数量，p 是可以接受的误判率，
+// p 必须在 (0, 1) 之间，否则使用默认的 0.01
+func NewLocalBloomFilter(n uint64, p float64) *LocalBloomFilter {
+	m, k := bloomParams(n, p)
+	return &LocalBloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+func (l *LocalBloomFilter) Add(ctx context.Context, keys ...string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, key := range keys {
+		for _, loc := range bloomLocations(key, l.m, l.k) {
+			l.bits[loc/64] |= 1 << (loc % 64)
+		}
+	}
+	return nil
+}
+
+func (l *LocalBloomFilter) Exist(ctx context.Context, key string) (bool, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	for _, loc := range bloomLocations(key, l.m, l.k) {
+		if l.bits[loc/64]&(1<<(loc%64)) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Rebuild 先在新的位数组上构建，再整体替换，构建期间不影响查询
+func (l *LocalBloomFilter) Rebuild(ctx context.Context, keys []string) error {
+	// m 不会变化，不需要加锁读取 l.bits 的长度
+	bits := make([]uint64, (l.m+63)/64)
+	for _, key := range keys {
+		for _, loc := range bloomLocations(key, l.m, l.k) {
+			bits[loc/64] |= 1 << (loc % 64)
+		}
+	}
+	l.mutex.Lock()
+	l.bits = bits
+	l.mutex.Unlock()
+	return nil
+}
+
+// RedisBloomFilter 使用 Redis 的 bitmap 保存位数组，多个实例可以共享同一个过滤器
+type RedisBloomFilter struct {
+	client redis.Cmdable
+	key    string
+	m      uint64
+	k      uint64
+}
+
+// NewRedisBloomFilter key 是 bitmap 在 Redis 中的 key，n 和 p 的含义同 NewLocalBloomFilter。
+// 共享同一个 key 的实例必须使用相同的 n 和 p
+func NewRedisBloomFilter(client redis.Cmdable, key string, n uint64, p float64) *RedisBloomFilter {
+	m, k := bloomParams(n, p)
+	return &RedisBloomFilter{
+		client: client,
+		key:    key,
+		m:      m,
+		k:      k,
+	}
+}
+
+func (r *RedisBloomFilter) Add(ctx context.Context, keys ...string) error {
+	return r.add(ctx, r.key, keys)
+}
+
+func (r *RedisBloomFilter) add(ctx context.Context, bitmap string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			for _, loc := range bloomLocations(key, r.m, r.k) {
+				pipe.SetBit(ctx, bitmap, int64(loc), 1)
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *RedisBloomFilter) Exist(ctx context.Context, key string) (bool, error) {
+	cmds, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, loc := range bloomLocations(key, r.m, r.k) {
+			pipe.GetBit(ctx, r.key, int64(loc))
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, cmd := range cmds {
+		if cmd.(*redis.IntCmd).Val() == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Rebuild 先写到临时的 key 里面，再通过 RENAME 原子替换。
+// 临时的 key 带有随机的后缀，并发的重建不会互相覆盖，最后一次 RENAME 的结果生效。
+// 在 Redis 集群上使用的时候，key 需要带上 hash tag，例如 {bloom}:users，
+// 这样临时的 key 和 key 才会在同一个 slot 上
+func (r *RedisBloomFilter) Rebuild(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return r.client.Del(ctx, r.key).Err()
+	}
+	tmp := r.key + ":rebuild:" + uuid.New().String()
+	if err := r.add(ctx, tmp, keys); err != nil {
+		_ = r.client.Del(ctx, tmp).Err()
+		return err
+	}
+	return r.client.Rename(ctx, tmp, r.key).Err()
+}
@@ -0,0 +1,51 @@
+package toycache
+
+import (
+	"context"
+	"github.com/aristletl/toycache/internal/errs"
+	"log"
+	"time"
+)
+
+// BloomFilterCache 在访问缓存之前先查询布隆过滤器，
+// 一定不存在的 key 直接返回 errs.ErrKeyNotFound，避免缓存穿透。
+// 过滤器需要提前使用 AddKeys 或者 Rebuild 把数据源里所有的 key 加进去
+type BloomFilterCache struct {
+	Cache
+	filter BloomFilter
+}
+
+func NewBloomFilterCache(cache Cache, filter BloomFilter) *BloomFilterCache {
+	return &BloomFilterCache{
+		Cache:  cache,
+		filter: filter,
+	}
+}
+
+func (b *BloomFilterCache) Get(ctx context.Context, key string) (any, error) {
+	ok, err := b.filter.Exist(ctx, key)
+	if err != nil {
+		// 过滤器不可用的时候当作 key 可能存在，退化成普通的缓存
+		log.Printf("cache: 查询布隆过滤器失败, key %s, err: %v", key, err)
+	} else if !ok {
+		return nil, errs.ErrKeyNotFound
+	}
+	return b.Cache.Get(ctx, key)
+}
+
+func (b *BloomFilterCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	if err := b.filter.Add(ctx, key); err != nil {
+		return err
+	}
+	return b.Cache.Set(ctx, key, val, expiration)
+}
+
+// AddKeys 把数据源中新增的 key 加入过滤器
+func (b *BloomFilterCache) AddKeys(ctx context.Context, keys ...string) error {
+	return b.filter.Add(ctx, keys...)
+}
+
+// Rebuild 使用数据源中全部的 key 重新构建过滤器
+func (b *BloomFilterCache) Rebuild(ctx context.Context, keys []string) error {
+	return b.filter.Rebuild(ctx, keys)
+}
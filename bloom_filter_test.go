@@ -0,0 +1,173 @@
+package toycache
+
+import (
+	"context"
+	"fmt"
+	"github.com/aristletl/toycache/internal/errs"
+	"github.com/go-redis/redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLocalBloomFilter(t *testing.T) {
+	ctx := context.Background()
+	f := NewLocalBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		assert.NoError(t, f.Add(ctx, fmt.Sprintf("key-%d", i)))
+	}
+	for i := 0; i < 1000; i++ {
+		ok, err := f.Exist(ctx, fmt.Sprintf("key-%d", i))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+
+	falsePositive := 0
+	for i := 0; i < 10000; i++ {
+		ok, _ := f.Exist(ctx, fmt.Sprintf("absent-%d", i))
+		if ok {
+			falsePositive++
+		}
+	}
+	assert.Less(t, falsePositive, 300)
+
+	assert.NoError(t, f.Rebuild(ctx, []string{"key-1"}))
+	ok, _ := f.Exist(ctx, "key-1")
+	assert.True(t, ok)
+	ok, _ = f.Exist(ctx, "key-2")
+	assert.False(t, ok)
+}
+
+func TestBloomParams(t *testing.T) {
+	m, k := bloomParams(100, 0.01)
+	// 误判率不合法的时候使用默认值
+	for _, p := range []float64{0, -1, 1, 2, math.NaN()} {
+		gotM, gotK := bloomParams(100, p)
+		assert.Equal(t, m, gotM)
+		assert.Equal(t, k, gotK)
+	}
+	assert.NotPanics(t, func() {
+		NewLocalBloomFilter(100, 0)
+	})
+}
+
+func TestRedisBloomFilter(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeRedisServer(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.addr()})
+	defer client.Close()
+
+	f := NewRedisBloomFilter(client, "bloom", 100, 0.01)
+	assert.NoError(t, f.Add(ctx))
+	keys := make([]string, 50)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	require.NoError(t, f.Add(ctx, keys...))
+	// 同一个 key 的过滤器是共享的
+	shared := NewRedisBloomFilter(client, "bloom", 100, 0.01)
+	for _, key := range keys {
+		ok, err := shared.Exist(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+	ok, err := f.Exist(ctx, "absent")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, f.Rebuild(ctx, []string{"key-100"}))
+	ok, _ = f.Exist(ctx, "key-100")
+	assert.True(t, ok)
+	ok, _ = f.Exist(ctx, "key-1")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"bloom"}, srv.keys("bloom"))
+
+	require.NoError(t, f.Rebuild(ctx, nil))
+	assert.Empty(t, srv.keys("bloom"))
+}
+
+func TestRedisBloomFilter_ConcurrentRebuild(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeRedisServer(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.addr()})
+	defer client.Close()
+
+	f := NewRedisBloomFilter(client, "bloom", 100, 0.01)
+	sets := [][]string{{"a-1", "a-2", "a-3"}, {"b-1", "b-2", "b-3"}}
+	var wg sync.WaitGroup
+	for _, keys := range sets {
+		wg.Add(1)
+		go func(keys []string) {
+			defer wg.Done()
+			assert.NoError(t, f.Rebuild(ctx, keys))
+		}(keys)
+	}
+	wg.Wait()
+
+	// 临时的 key 互不干扰，最终的结果是其中一次重建的结果，而不是两次的混合
+	exist := func(keys []string) int {
+		cnt := 0
+		for _, key := range keys {
+			if ok, _ := f.Exist(ctx, key); ok {
+				cnt++
+			}
+		}
+		return cnt
+	}
+	a, b := exist(sets[0]), exist(sets[1])
+	assert.True(t, (a == 3 && b == 0) || (a == 0 && b == 3), "a: %d, b: %d", a, b)
+	assert.Equal(t, []string{"bloom"}, srv.keys("bloom"))
+}
+
+func TestBloomFilterCache_Get(t *testing.T) {
+	ctx := context.Background()
+	local := NewLocalCache()
+	defer local.Close()
+	loaded := 0
+	rt := NewReadThroughCache(local, func(ctx context.Context, key string) (any, error) {
+		loaded++
+		if key == "exist" {
+			return "value", nil
+		}
+		return nil, errs.ErrKeyNotFound
+	}, time.Minute)
+	c := NewBloomFilterCache(rt, NewLocalBloomFilter(100, 0.01))
+	assert.NoError(t, c.AddKeys(ctx, "exist"))
+
+	val, err := c.Get(ctx, "exist")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+
+	for i := 0; i < 10; i++ {
+		_, err = c.Get(ctx, "absent")
+		assert.Equal(t, errs.ErrKeyNotFound, err)
+	}
+	assert.Equal(t, 1, loaded)
+}
+
+func TestLocalBloomFilter_ConcurrentRebuild(t *testing.T) {
+	ctx := context.Background()
+	f := NewLocalBloomFilter(100, 0.01)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, f.Rebuild(ctx, []string{fmt.Sprintf("key-%d", i)}))
+		}(i)
+	}
+	wg.Wait()
+	// 最后一次重建的结果生效，只有一个 key 存在
+	exist := 0
+	for i := 0; i < 4; i++ {
+		ok, err := f.Exist(ctx, fmt.Sprintf("key-%d", i))
+		require.NoError(t, err)
+		if ok {
+			exist++
+		}
+	}
+	assert.Equal(t, 1, exist)
+}
@@ -0,0 +1,188 @@
+package toycache
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v9"
+	"github.com/google/uuid"
+	"log"
+	"sync"
+	"time"
+)
+
+// PubSubCmdable 是支持发布订阅的 Redis 客户端，
+// *redis.Client、*redis.ClusterClient 和 *redis.Ring 都满足这个接口
+type PubSubCmdable interface {
+	redis.Cmdable
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+const (
+	broadcastOpSet = "set"
+	broadcastOpDel = "del"
+)
+
+// broadcastMessage 是在频道上传播的失效消息
+type broadcastMessage struct {
+	// ID 是发送方实例的 ID，用于忽略自己发出去的消息
+	ID  string `json:"id"`
+	Op  string `json:"op"`
+	Key string `json:"key"`
+}
+
+type BroadcastCacheOption func(b *BroadcastCache)
+
+// clearer 是能够清空所有数据的 Cache，例如 LocalCache
+type clearer interface {
+	Clear(ctx context.Context) error
+}
+
+// BroadcastCache 让多个实例的本地缓存保持一致。
+// 本实例修改或者删除 key 之后，会在 Redis 的频道上广播一条失效消息，
+// 其它实例收到之后删除自己本地的 key，下一次读取的时候再重新加载。
+// 断线期间的失效消息是收不到的，重新连上之后，如果本地缓存支持 Clear（例如 LocalCache），
+// 会清空本地缓存；不支持的话，本地缓存在过期之前都可能是旧数据
+type BroadcastCache struct {
+	Cache
+	client  PubSubCmdable
+	channel string
+	id      string
+
+	retryInterval time.Duration
+	onReconnect   func()
+
+	pubsub *redis.PubSub
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewBroadcastCache local 是本地缓存，一般是 LocalCache，channel 是广播使用的频道
+func NewBroadcastCache(local Cache, client PubSubCmdable, channel string,
+	opts ...BroadcastCacheOption) *BroadcastCache {
+	ctx, cancel := context.WithCancel(context.Background())
+	res := &BroadcastCache{
+		Cache:         local,
+		client:        client,
+		channel:       channel,
+		id:            uuid.New().String(),
+		retryInterval: time.Second,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	res.pubsub = client.Subscribe(ctx, channel)
+	go res.loop(ctx)
+	return res
+}
+
+// WithBroadcastRetryInterval 设置接收消息出错之后重试的时间间隔
+func WithBroadcastRetryInterval(interval time.Duration) BroadcastCacheOption {
+	return func(b *BroadcastCache) {
+		b.retryInterval = interval
+	}
+}
+
+// WithOnReconnect 设置重新连上 Redis 之后的回调，在清空本地缓存之后调用。
+// 本地缓存不支持 Clear 的时候，可以在回调里自己清理
+func WithOnReconnect(fn func()) BroadcastCacheOption {
+	return func(b *BroadcastCache) {
+		b.onReconnect = fn
+	}
+}
+
+// Set 写入本地缓存之后通知其它实例删除旧数据，
+// 返回的错误可能来自于广播，此时本地缓存已经写入成功
+func (b *BroadcastCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	if err := b.Cache.Set(ctx, key, val, expiration); err != nil {
+		return err
+	}
+	return b.publish(ctx, broadcastOpSet, key)
+}
+
+func (b *BroadcastCache) Delete(ctx context.Context, key string) error {
+	if err := b.Cache.Delete(ctx, key); err != nil {
+		return err
+	}
+	return b.publish(ctx, broadcastOpDel, key)
+}
+
+// Close 停止接收失效消息，不会关闭本地缓存
+func (b *BroadcastCache) Close() error {
+	var err error
+	b.once.Do(func() {
+		b.cancel()
+		err = b.pubsub.Close()
+		<-b.done
+	})
+	return err
+}
+
+func (b *BroadcastCache) publish(ctx context.Context, op, key string) error {
+	msg, err := json.Marshal(broadcastMessage{ID: b.id, Op: op, Key: key})
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channel, msg).Err()
+}
+
+func (b *BroadcastCache) loop(ctx context.Context) {
+	defer close(b.done)
+	subscribed := false
+	for {
+		msg, err := b.pubsub.Receive(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// PubSub 会在下一次 Receive 的时候重新建立连接并且重新订阅
+			log.Printf("cache: 接收失效消息失败, err: %v", err)
+			select {
+			case <-time.After(b.retryInterval):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if m.Kind != "subscribe" {
+				continue
+			}
+			if subscribed {
+				b.reconnected(ctx)
+			}
+			subscribed = true
+		case *redis.Message:
+			b.apply(ctx, m.Payload)
+		}
+	}
+}
+
+func (b *BroadcastCache) reconnected(ctx context.Context) {
+	if c, ok := b.Cache.(clearer); ok {
+		if err := c.Clear(ctx); err != nil {
+			log.Printf("cache: 清空本地缓存失败, err: %v", err)
+		}
+	}
+	if b.onReconnect != nil {
+		b.onReconnect()
+	}
+}
+
+func (b *BroadcastCache) apply(ctx context.Context, payload string) {
+	var msg broadcastMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		log.Printf("cache: 非法的失效消息 %s, err: %v", payload, err)
+		return
+	}
+	if msg.ID == b.id {
+		return
+	}
+	if err := b.Cache.Delete(ctx, msg.Key); err != nil {
+		log.Printf("cache: 删除本地缓存失败, key %s, err: %v", msg.Key, err)
+	}
+}
@@ -0,0 +1,449 @@
+package toycache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/aristletl/toycache/internal/errs"
+	"github.com/go-redis/redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBroadcastCache(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeRedisServer(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.addr()})
+	defer client.Close()
+
+	var reconnects int32
+	newCache := func() (*LocalCache, *BroadcastCache) {
+		local := NewLocalCache()
+		c := NewBroadcastCache(local, client, "invalidate",
+			WithBroadcastRetryInterval(10*time.Millisecond),
+			WithOnReconnect(func() {
+				atomic.AddInt32(&reconnects, 1)
+			}))
+		return local, c
+	}
+	local1, c1 := newCache()
+	defer c1.Close()
+	local2, c2 := newCache()
+	defer c2.Close()
+	waitSubscribers := func() {
+		require.Eventually(t, func() bool {
+			return srv.subscribers("invalidate") == 2
+		}, time.Second, time.Millisecond)
+	}
+	waitSubscribers()
+
+	require.NoError(t, local2.Set(ctx, "key", "old", time.Minute))
+	require.NoError(t, c1.Set(ctx, "key", "v1", time.Minute))
+	assert.Eventually(t, func() bool {
+		_, err := local2.Get(ctx, "key")
+		return err == errs.ErrKeyNotFound
+	}, time.Second, time.Millisecond)
+	// 自己发出的消息不会删除自己的数据
+	time.Sleep(10 * time.Millisecond)
+	val, err := local1.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val)
+
+	// 断线重连之后清空本地缓存，并且依旧可以收到失效消息
+	srv.dropConns()
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&reconnects) == 2
+	}, time.Second, time.Millisecond)
+	_, err = local1.Get(ctx, "key")
+	assert.Equal(t, errs.ErrKeyNotFound, err)
+	waitSubscribers()
+	require.NoError(t, local2.Set(ctx, "key", "old", time.Minute))
+	require.NoError(t, c1.Delete(ctx, "key"))
+	assert.Eventually(t, func() bool {
+		_, err := local2.Get(ctx, "key")
+		return err == errs.ErrKeyNotFound
+	}, time.Second, time.Millisecond)
+}
+
+// fakeRedisServer 是一个 Redis 替身，支持发布订阅、简单的读写、bitmap 以及 CLIENT TRACKING 的重定向模式，
+// 测试的时候不需要依赖真实的 Redis
+type fakeRedisServer struct {
+	listener net.Listener
+
+	mutex  sync.Mutex
+	conns  map[*fakeRedisConn]struct{}
+	nextID int64
+	data   map[string]string
+	// trackers 记录 key 被哪些连接读过，值是接收失效消息的连接 ID
+	trackers map[string]map[int64]struct{}
+}
+
+type fakeRedisConn struct {
+	net.Conn
+	id       int64
+	mutex    sync.Mutex
+	channels map[string]struct{}
+	patterns map[string]struct{}
+
+	tracking bool
+	redirect int64
+	bcast    bool
+	prefixes []string
+}
+
+func newFakeRedisServer(t *testing.T) *fakeRedisServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &fakeRedisServer{
+		listener: listener,
+		conns:    make(map[*fakeRedisConn]struct{}),
+		data:     make(map[string]string),
+		trackers: make(map[string]map[int64]struct{}),
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+		srv.dropConns()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			c := &fakeRedisConn{
+				Conn:     conn,
+				channels: make(map[string]struct{}),
+				patterns: make(map[string]struct{}),
+			}
+			srv.mutex.Lock()
+			srv.nextID++
+			c.id = srv.nextID
+			srv.conns[c] = struct{}{}
+			srv.mutex.Unlock()
+			go srv.serve(c)
+		}
+	}()
+	return srv
+}
+
+func (s *fakeRedisServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeRedisServer) subscribers(channel string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cnt := 0
+	for c := range s.conns {
+		c.mutex.Lock()
+		if _, ok := c.channels[channel]; ok {
+			cnt++
+		}
+		c.mutex.Unlock()
+	}
+	return cnt
+}
+
+// dropConns 断开所有的连接，模拟网络故障
+func (s *fakeRedisServer) dropConns() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for c := range s.conns {
+		_ = c.Close()
+		delete(s.conns, c)
+	}
+}
+
+func (s *fakeRedisServer) serve(c *fakeRedisConn) {
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, c)
+		s.mutex.Unlock()
+		_ = c.Close()
+	}()
+	reader := bufio.NewReader(c)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		switch strings.ToLower(args[0]) {
+		case "ping":
+			c.write("+PONG\r\n")
+		case "publish":
+			c.write(fmt.Sprintf(":%d\r\n", s.publish(args[1], args[2])))
+		case "subscribe":
+			c.mutex.Lock()
+			for _, ch := range args[1:] {
+				c.channels[ch] = struct{}{}
+			}
+			c.mutex.Unlock()
+			for i, ch := range args[1:] {
+				c.write(fmt.Sprintf("*3\r\n$9\r\nsubscribe\r\n%s:%d\r\n", bulkString(ch), i+1))
+			}
+		case "psubscribe":
+			c.mutex.Lock()
+			for _, p := range args[1:] {
+				c.patterns[p] = struct{}{}
+			}
+			c.mutex.Unlock()
+			for i, p := range args[1:] {
+				c.write(fmt.Sprintf("*3\r\n$10\r\npsubscribe\r\n%s:%d\r\n", bulkString(p), i+1))
+			}
+		case "client":
+			s.client(c, args)
+		case "get":
+			s.mutex.Lock()
+			val, ok := s.data[args[1]]
+			if c.tracking && !c.bcast {
+				if s.trackers[args[1]] == nil {
+					s.trackers[args[1]] = make(map[int64]struct{})
+				}
+				s.trackers[args[1]][c.redirect] = struct{}{}
+			}
+			s.mutex.Unlock()
+			if !ok {
+				c.write("$-1\r\n")
+				continue
+			}
+			c.write(bulkString(val))
+		case "set":
+			s.mutex.Lock()
+			s.data[args[1]] = args[2]
+			s.invalidate(args[1])
+			s.mutex.Unlock()
+			c.write("+OK\r\n")
+		case "del":
+			s.mutex.Lock()
+			_, ok := s.data[args[1]]
+			delete(s.data, args[1])
+			s.invalidate(args[1])
+			if ok {
+				s.notifyKeyspace(args[1], "del")
+			}
+			s.mutex.Unlock()
+			if ok {
+				c.write(":1\r\n")
+				continue
+			}
+			c.write(":0\r\n")
+		case "setbit":
+			offset, _ := strconv.ParseInt(args[2], 10, 64)
+			s.mutex.Lock()
+			data := []byte(s.data[args[1]])
+			if need := int(offset/8) + 1; len(data) < need {
+				data = append(data, make([]byte, need-len(data))...)
+			}
+			mask := byte(1) << (7 - uint(offset%8))
+			old := 0
+			if data[offset/8]&mask != 0 {
+				old = 1
+			}
+			if args[3] == "1" {
+				data[offset/8] |= mask
+			} else {
+				data[offset/8] &^= mask
+			}
+			s.data[args[1]] = string(data)
+			s.mutex.Unlock()
+			c.write(fmt.Sprintf(":%d\r\n", old))
+		case "getbit":
+			offset, _ := strconv.ParseInt(args[2], 10, 64)
+			s.mutex.Lock()
+			data := s.data[args[1]]
+			s.mutex.Unlock()
+			bit := 0
+			if int(offset/8) < len(data) && data[offset/8]&(byte(1)<<(7-uint(offset%8))) != 0 {
+				bit = 1
+			}
+			c.write(fmt.Sprintf(":%d\r\n", bit))
+		case "rename":
+			s.mutex.Lock()
+			val, ok := s.data[args[1]]
+			if ok {
+				delete(s.data, args[1])
+				s.data[args[2]] = val
+			}
+			s.mutex.Unlock()
+			if !ok {
+				c.write("-ERR no such key\r\n")
+				continue
+			}
+			c.write("+OK\r\n")
+		default:
+			c.write(fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0]))
+		}
+	}
+}
+
+// expire 模拟 key 过期
+func (s *fakeRedisServer) expire(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.data, key)
+	s.invalidate(key)
+	s.notifyKeyspace(key, "expired")
+}
+
+// notifyKeyspace 发送 db 0 的 keyspace 通知
+func (s *fakeRedisServer) notifyKeyspace(key, event string) {
+	channel := "__keyspace@0__:" + key
+	for c := range s.conns {
+		c.mutex.Lock()
+		var matched []string
+		for p := range c.patterns {
+			if ok, _ := path.Match(p, channel); ok {
+				matched = append(matched, p)
+			}
+		}
+		c.mutex.Unlock()
+		for _, p := range matched {
+			c.write(fmt.Sprintf("*4\r\n$8\r\npmessage\r\n%s%s%s",
+				bulkString(p), bulkString(channel), bulkString(event)))
+		}
+	}
+}
+
+// keys 返回所有以 prefix 开头的 key
+func (s *fakeRedisServer) keys(prefix string) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var res []string
+	for key := range s.data {
+		if strings.HasPrefix(key, prefix) {
+			res = append(res, key)
+		}
+	}
+	return res
+}
+
+func (s *fakeRedisServer) psubscribers() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cnt := 0
+	for c := range s.conns {
+		c.mutex.Lock()
+		cnt += len(c.patterns)
+		c.mutex.Unlock()
+	}
+	return cnt
+}
+
+func (s *fakeRedisServer) client(c *fakeRedisConn, args []string) {
+	switch strings.ToLower(args[1]) {
+	case "id":
+		c.write(fmt.Sprintf(":%d\r\n", c.id))
+	case "tracking":
+		s.mutex.Lock()
+		c.tracking = strings.ToLower(args[2]) == "on"
+		for i := 3; i < len(args); i++ {
+			switch strings.ToLower(args[i]) {
+			case "redirect":
+				c.redirect, _ = strconv.ParseInt(args[i+1], 10, 64)
+				i++
+			case "bcast":
+				c.bcast = true
+			case "prefix":
+				c.prefixes = append(c.prefixes, args[i+1])
+				i++
+			}
+		}
+		s.mutex.Unlock()
+		c.write("+OK\r\n")
+	default:
+		c.write("-ERR unknown subcommand\r\n")
+	}
+}
+
+// invalidate 向读过 key 的连接以及广播模式下前缀匹配的连接发送失效消息
+func (s *fakeRedisServer) invalidate(key string) {
+	targets := s.trackers[key]
+	delete(s.trackers, key)
+	if targets == nil {
+		targets = make(map[int64]struct{})
+	}
+	for c := range s.conns {
+		if !c.tracking || !c.bcast {
+			continue
+		}
+		match := len(c.prefixes) == 0
+		for _, prefix := range c.prefixes {
+			if strings.HasPrefix(key, prefix) {
+				match = true
+			}
+		}
+		if match {
+			targets[c.redirect] = struct{}{}
+		}
+	}
+	for c := range s.conns {
+		if _, ok := targets[c.id]; ok {
+			c.write(fmt.Sprintf("*3\r\n$7\r\nmessage\r\n%s*1\r\n%s",
+				bulkString(trackingChannel), bulkString(key)))
+		}
+	}
+}
+
+func (s *fakeRedisServer) publish(channel, payload string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cnt := 0
+	for c := range s.conns {
+		c.mutex.Lock()
+		_, ok := c.channels[channel]
+		c.mutex.Unlock()
+		if ok {
+			c.write(fmt.Sprintf("*3\r\n$7\r\nmessage\r\n%s%s", bulkString(channel), bulkString(payload)))
+			cnt++
+		}
+	}
+	return cnt
+}
+
+func (c *fakeRedisConn) write(reply string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, _ = io.WriteString(c.Conn, reply)
+}
+
+func bulkString(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// readCommand 读取一个 RESP 数组形式的命令
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
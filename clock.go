@@ -0,0 +1,148 @@
+package toycache
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Clock 是 CLOCK（second chance）淘汰策略。
+// 每个 key 有一个访问位，访问已有的 key 只需要加读锁并且原子地设置访问位，不需要移动链表节点，
+// 所以在并发读多的场景下比 LRU 更容易扩展。
+// 淘汰的时候从最早进入的 key 开始检查，访问位被设置过的 key 清除访问位之后重新排到队尾，
+// 第一个没有访问位的 key 就是被淘汰的 key
+type Clock struct {
+	sync.RWMutex
+	queue *linkedList
+	cache map[any]*atomicEntry
+}
+
+// atomicEntry 是 Clock 和 S3FIFO 的 key，
+// freq 和 value 都可以在只持有读锁的时候原子地修改
+type atomicEntry struct {
+	node  *node
+	freq  int32
+	value atomic.Value
+	// small 表示 key 在 S3FIFO 的小队列里
+	small bool
+}
+
+// valueBox 让 atomic.Value 里面保存的总是同一个类型
+type valueBox struct {
+	val any
+}
+
+func newAtomicEntry(key, val any) *atomicEntry {
+	res := &atomicEntry{node: &node{key: key}}
+	res.value.Store(valueBox{val: val})
+	return res
+}
+
+func (e *atomicEntry) load() any {
+	return e.value.Load().(valueBox).val
+}
+
+// touch 更新值并且把访问次数加一，访问次数最多为 max。
+// 值没有变化的时候不会重新写入，命中的时候只需要修改访问次数
+func (e *atomicEntry) touch(val any, max int32) {
+	if !sameValue(e.load(), val) {
+		e.value.Store(valueBox{val: val})
+	}
+	for {
+		freq := atomic.LoadInt32(&e.freq)
+		if freq >= max || atomic.CompareAndSwapInt32(&e.freq, freq, freq+1) {
+			return
+		}
+	}
+}
+
+// sameValue 判断两个值是否相等。只比较类型相同的基础类型和指针，
+// 结构体和数组里面可能有不可比较的字段，这些情况都认为不相等，直接重新写入
+func sameValue(a, b any) bool {
+	ta := reflect.TypeOf(a)
+	if ta == nil || ta != reflect.TypeOf(b) {
+		return false
+	}
+	switch ta.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128,
+		reflect.String, reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return a == b
+	default:
+		return false
+	}
+}
+
+func NewClock() *Clock {
+	return &Clock{
+		queue: newLinkedList(),
+		cache: make(map[any]*atomicEntry),
+	}
+}
+
+func (c *Clock) Get(key any) (AnyValue, bool) {
+	c.RLock()
+	e, ok := c.cache[key]
+	c.RUnlock()
+	if !ok {
+		return AnyValue{}, false
+	}
+	return AnyValue{Val: e.load()}, true
+}
+
+func (c *Clock) GetEliminatedKey() (any, AnyValue, bool) {
+	c.Lock()
+	defer c.Unlock()
+	for {
+		n := c.queue.back()
+		if n == nil {
+			return nil, AnyValue{}, false
+		}
+		e := c.cache[n.key]
+		if atomic.SwapInt32(&e.freq, 0) == 0 {
+			return n.key, AnyValue{Val: e.load()}, true
+		}
+		c.queue.moveToFront(n)
+	}
+}
+
+func (c *Clock) Add(key any, args ...any) {
+	if key == nil {
+		return
+	}
+	var val any
+	if len(args) != 0 {
+		val = args[0]
+	}
+
+	c.RLock()
+	e, ok := c.cache[key]
+	c.RUnlock()
+	if ok {
+		e.touch(val, 1)
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if e, ok = c.cache[key]; ok {
+		e.touch(val, 1)
+		return
+	}
+	e = newAtomicEntry(key, val)
+	c.cache[key] = e
+	c.queue.pushFront(e.node)
+}
+
+func (c *Clock) Remove(key any) bool {
+	c.Lock()
+	defer c.Unlock()
+	e, ok := c.cache[key]
+	if !ok {
+		return false
+	}
+	delete(c.cache, key)
+	c.queue.remove(e.node)
+	return true
+}
@@ -0,0 +1,74 @@
+package toycache
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestClock(t *testing.T) {
+	c := NewClock()
+	_, _, ok := c.GetEliminatedKey()
+	assert.False(t, ok)
+
+	for i := 1; i <= 4; i++ {
+		c.Add(i, i)
+	}
+	// 访问过的 key 获得第二次机会
+	c.Add(1, 10)
+	c.Add(3, 3)
+	val, ok := c.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, 10, val.Val)
+
+	assert.Equal(t, []any{2, 4, 1, 3}, evictN(t, c, 4))
+	assert.False(t, c.Remove(1))
+}
+
+func TestAtomicEntry_Touch(t *testing.T) {
+	e := newAtomicEntry("a", []byte("x"))
+	// 不可比较的值也要能够更新
+	e.touch([]byte("y"), 1)
+	assert.Equal(t, []byte("y"), e.load())
+	// 结构体里面有不可比较的字段也不能 panic
+	e.touch(struct{ val any }{val: []int{1}}, 1)
+	e.touch(struct{ val any }{val: []int{1}}, 1)
+	e.touch(int64(1), 1)
+	e.touch(int64(1), 1)
+	assert.Equal(t, int64(1), e.load())
+	assert.Equal(t, int32(1), e.freq)
+}
+
+func BenchmarkStrategyParallelAdd(b *testing.B) {
+	const keys = 1024
+	strategies := []struct {
+		name   string
+		newFun func() EliminateStrategy
+	}{
+		{name: "LRU", newFun: func() EliminateStrategy { return NewLRU() }},
+		{name: "Clock", newFun: func() EliminateStrategy { return NewClock() }},
+		{name: "S3FIFO", newFun: func() EliminateStrategy { return NewS3FIFO(keys) }},
+	}
+	for _, s := range strategies {
+		b.Run(s.name, func(b *testing.B) {
+			strategy := s.newFun()
+			// key 和值提前准备好，避免格式化和装箱的开销计入结果
+			names := make([]any, keys)
+			costs := make([]any, keys)
+			for i := 0; i < keys; i++ {
+				names[i], costs[i] = fmt.Sprint(i), int64(i)
+				strategy.Add(names[i], costs[i])
+			}
+			trace := zipfTrace(4096, keys, 1.01)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					key := trace[i%len(trace)]
+					strategy.Add(names[key], costs[key])
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package toycache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec 负责把值转换成可以存储的字节，以及反向转换
+type Codec interface {
+	Marshal(val any) ([]byte, error)
+	// Unmarshal 把 data 解析到 val 中，val 必须是指针
+	Unmarshal(data []byte, val any) error
+}
+
+// JSONCodec 使用 JSON 编解码，可读性好，也方便其它语言读取
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(val any) ([]byte, error) {
+	return json.Marshal(val)
+}
+
+func (JSONCodec) Unmarshal(data []byte, val any) error {
+	return json.Unmarshal(data, val)
+}
+
+// GobCodec 使用 gob 编解码，只适用于 Go 服务之间共享的数据
+type GobCodec struct{}
+
+func (GobCodec) Marshal(val any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(val); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, val any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(val)
+}
@@ -0,0 +1,269 @@
+package toycache
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"github.com/aristletl/toycache/internal/errs"
+	"io"
+	"reflect"
+	"time"
+)
+
+// compressMagic 是压缩层写入的头部的第一个字节，
+// 头部一共两个字节：magic + 压缩算法 ID，ID 为 0 表示没有压缩
+const compressMagic byte = 0xfc
+
+const (
+	compressNone byte = iota
+	compressGzip
+	compressZlib
+	compressFlate
+)
+
+// Compressor 压缩算法
+type Compressor interface {
+	// ID 会被写入头部，解压的时候用来找到对应的算法。
+	// 不能为 0，也不能和内置的算法（1 到 3）重复，否则 NewCompressCache 返回 errs.ErrInvalidCompressor
+	ID() byte
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// GzipCompressor 压缩率比较高，适合大的 JSON 文档
+type GzipCompressor struct {
+	Level int
+}
+
+func (GzipCompressor) ID() byte {
+	return compressGzip
+}
+
+func (g GzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	return finishCompress(&buf, w, data)
+}
+
+func (GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// ZlibCompressor 和 gzip 是同一个算法，头部更小
+type ZlibCompressor struct {
+	Level int
+}
+
+func (ZlibCompressor) ID() byte {
+	return compressZlib
+}
+
+func (z ZlibCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	level := z.Level
+	if level == 0 {
+		level = zlib.DefaultCompression
+	}
+	w, err := zlib.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	return finishCompress(&buf, w, data)
+}
+
+func (ZlibCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// FlateCompressor 默认使用 flate.BestSpeed，用压缩率换速度，
+// 定位上类似 snappy 之类的快速压缩算法
+type FlateCompressor struct {
+	Level int
+}
+
+func (FlateCompressor) ID() byte {
+	return compressFlate
+}
+
+func (f FlateCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	level := f.Level
+	if level == 0 {
+		level = flate.BestSpeed
+	}
+	w, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	return finishCompress(&buf, w, data)
+}
+
+func (FlateCompressor) Decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func finishCompress(buf *bytes.Buffer, w io.WriteCloser, data []byte) ([]byte, error) {
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type CompressCacheOption func(c *CompressCache)
+
+// CompressCache 对超过阈值的值进行压缩之后再写入 Cache，
+// 读取的时候根据头部判断是否需要解压，所以压缩过的和没压缩过的数据可以共存。
+// 没有头部的数据会被原样返回，方便从没有压缩的老数据迁移过来。
+// 注意老数据如果恰好以 0xfc 开头，会被当成带有头部的数据：
+// 第二个字节是未知的算法 ID 的时候返回 errs.ErrUnknownCompression，
+// 是已知的算法 ID 的时候返回解压的错误，迁移之前需要确认老数据不会以 0xfc 开头
+type CompressCache struct {
+	Cache
+	compressor  Compressor
+	threshold   int
+	compressors map[byte]Compressor
+}
+
+// NewCompressCache 压缩算法的 ID 不合法的时候返回 errs.ErrInvalidCompressor
+func NewCompressCache(cache Cache, opts ...CompressCacheOption) (*CompressCache, error) {
+	res := &CompressCache{
+		Cache:      cache,
+		compressor: GzipCompressor{},
+		threshold:  1024,
+		compressors: map[byte]Compressor{
+			compressGzip:  GzipCompressor{},
+			compressZlib:  ZlibCompressor{},
+			compressFlate: FlateCompressor{},
+		},
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	id := res.compressor.ID()
+	if id == compressNone {
+		return nil, fmt.Errorf("%w: ID 不能为 0", errs.ErrInvalidCompressor)
+	}
+	// 同一种算法不同的压缩级别可以替换内置的实现，解压的方式是一样的
+	if builtin, ok := res.compressors[id]; ok && reflect.TypeOf(builtin) != reflect.TypeOf(res.compressor) {
+		return nil, fmt.Errorf("%w: ID %d 和内置的 %T 重复", errs.ErrInvalidCompressor, id, builtin)
+	}
+	res.compressors[id] = res.compressor
+	return res, nil
+}
+
+// WithCompressor 设置写入时使用的压缩算法
+func WithCompressor(compressor Compressor) CompressCacheOption {
+	return func(c *CompressCache) {
+		c.compressor = compressor
+	}
+}
+
+// WithCompressThreshold 只有值的长度大于等于 threshold 的时候才会压缩
+func WithCompressThreshold(threshold int) CompressCacheOption {
+	return func(c *CompressCache) {
+		c.threshold = threshold
+	}
+}
+
+// Get 返回解压之后的 []byte
+func (c *CompressCache) Get(ctx context.Context, key string) (any, error) {
+	val, err := c.Cache.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := toBytes(val)
+	if !ok {
+		return nil, errs.ErrUnsupportedValue
+	}
+	return c.decode(data)
+}
+
+// Set 只接受 []byte 或者 string
+func (c *CompressCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	data, ok := toBytes(val)
+	if !ok {
+		return errs.ErrUnsupportedValue
+	}
+	data, err := c.encode(data)
+	if err != nil {
+		return err
+	}
+	return c.Cache.Set(ctx, key, data, expiration)
+}
+
+func (c *CompressCache) OnEvicted(fn func(key string, val []byte)) {
+	c.Cache.OnEvicted(func(key string, val []byte) {
+		data, err := c.decode(val)
+		if err != nil {
+			data = val
+		}
+		fn(key, data)
+	})
+}
+
+func (c *CompressCache) encode(data []byte) ([]byte, error) {
+	if len(data) < c.threshold {
+		res := make([]byte, 0, len(data)+2)
+		res = append(res, compressMagic, compressNone)
+		return append(res, data...), nil
+	}
+	compressed, err := c.compressor.Compress(data)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]byte, 0, len(compressed)+2)
+	res = append(res, compressMagic, c.compressor.ID())
+	return append(res, compressed...), nil
+}
+
+func (c *CompressCache) decode(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != compressMagic {
+		return data, nil
+	}
+	if data[1] == compressNone {
+		return data[2:], nil
+	}
+	compressor, ok := c.compressors[data[1]]
+	if !ok {
+		return nil, errs.ErrUnknownCompression
+	}
+	return compressor.Decompress(data[2:])
+}
+
+// toBytes 把 string 和 []byte 统一成 []byte，
+// RedisCache 读出来的是 string，LocalCache 读出来的是写入时的 []byte
+func toBytes(val any) ([]byte, bool) {
+	switch v := val.(type) {
+	case []byte:
+		return v, true
+	case string:
+		return []byte(v), true
+	default:
+		return nil, false
+	}
+}
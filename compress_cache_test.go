@@ -0,0 +1,174 @@
+package toycache
+
+import (
+	"bytes"
+	"context"
+	"github.com/aristletl/toycache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCompressCache(t *testing.T) {
+	large := bytes.Repeat([]byte("toycache"), 512)
+	testCase := []struct {
+		name string
+		opts []CompressCacheOption
+		val  any
+		// wantID 是写入之后头部记录的算法 ID
+		wantID byte
+	}{
+		{
+			name:   "gzip",
+			val:    large,
+			wantID: compressGzip,
+		},
+		{
+			name:   "zlib",
+			opts:   []CompressCacheOption{WithCompressor(ZlibCompressor{})},
+			val:    large,
+			wantID: compressZlib,
+		},
+		{
+			name:   "flate",
+			opts:   []CompressCacheOption{WithCompressor(FlateCompressor{})},
+			val:    string(large),
+			wantID: compressFlate,
+		},
+		{
+			name:   "小于阈值不压缩",
+			opts:   []CompressCacheOption{WithCompressThreshold(len(large) + 1)},
+			val:    large,
+			wantID: compressNone,
+		},
+		{
+			name:   "等于阈值压缩",
+			opts:   []CompressCacheOption{WithCompressThreshold(len(large))},
+			val:    large,
+			wantID: compressGzip,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			local := NewLocalCache()
+			defer local.Close()
+			c, err := NewCompressCache(local, tc.opts...)
+			require.NoError(t, err)
+			require.NoError(t, c.Set(ctx, "key", tc.val, time.Minute))
+
+			raw, err := local.Get(ctx, "key")
+			require.NoError(t, err)
+			data := raw.([]byte)
+			assert.Equal(t, compressMagic, data[0])
+			assert.Equal(t, tc.wantID, data[1])
+			if tc.wantID != compressNone {
+				assert.Less(t, len(data), len(large))
+			}
+
+			val, err := c.Get(ctx, "key")
+			require.NoError(t, err)
+			assert.Equal(t, large, val)
+		})
+	}
+}
+
+func TestCompressCache_Decode(t *testing.T) {
+	ctx := context.Background()
+	local := NewLocalCache()
+	defer local.Close()
+	c, err := NewCompressCache(local, WithCompressThreshold(10))
+	require.NoError(t, err)
+
+	// 压缩过的、没压缩过的以及没有头部的老数据可以共存
+	require.NoError(t, c.Set(ctx, "compressed", "hello, toycache", time.Minute))
+	require.NoError(t, c.Set(ctx, "plain", "hello", time.Minute))
+	require.NoError(t, local.Set(ctx, "legacy", "legacy value", time.Minute))
+	require.NoError(t, local.Set(ctx, "short", []byte{compressMagic}, time.Minute))
+	// 以 0xfc 开头的老数据会被当成带有头部的数据
+	require.NoError(t, local.Set(ctx, "collision", []byte{compressMagic, 0xee, 'a'}, time.Minute))
+	require.NoError(t, local.Set(ctx, "number", 123, time.Minute))
+
+	testCase := []struct {
+		key     string
+		wantVal any
+		wantErr error
+	}{
+		{key: "compressed", wantVal: []byte("hello, toycache")},
+		{key: "plain", wantVal: []byte("hello")},
+		{key: "legacy", wantVal: []byte("legacy value")},
+		{key: "short", wantVal: []byte{compressMagic}},
+		{key: "collision", wantErr: errs.ErrUnknownCompression},
+		{key: "number", wantErr: errs.ErrUnsupportedValue},
+		{key: "missing", wantErr: errs.ErrKeyNotFound},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.key, func(t *testing.T) {
+			val, err := c.Get(ctx, tc.key)
+			assert.Equal(t, tc.wantErr, err)
+			if tc.wantErr == nil {
+				assert.Equal(t, tc.wantVal, val)
+			}
+		})
+	}
+
+	// 第二个字节恰好是已知的算法 ID，返回的是解压的错误
+	require.NoError(t, local.Set(ctx, "corrupt", []byte{compressMagic, compressGzip, 'a'}, time.Minute))
+	_, err = c.Get(ctx, "corrupt")
+	assert.Error(t, err)
+
+	assert.Equal(t, errs.ErrUnsupportedValue, c.Set(ctx, "key", 123, time.Minute))
+}
+
+func TestCompressCache_OnEvicted(t *testing.T) {
+	ctx := context.Background()
+	local := NewLocalCache()
+	defer local.Close()
+	c, err := NewCompressCache(local, WithCompressThreshold(1))
+	require.NoError(t, err)
+	var evicted []byte
+	c.OnEvicted(func(key string, val []byte) {
+		evicted = val
+	})
+	require.NoError(t, c.Set(ctx, "key", "hello", time.Minute))
+	require.NoError(t, c.Delete(ctx, "key"))
+	assert.Equal(t, []byte("hello"), evicted)
+}
+
+// idCompressor 是 ID 可以随意指定的 Compressor，不做任何压缩
+type idCompressor byte
+
+func (c idCompressor) ID() byte {
+	return byte(c)
+}
+
+func (idCompressor) Compress(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (idCompressor) Decompress(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func TestNewCompressCache(t *testing.T) {
+	testCase := []struct {
+		name       string
+		compressor Compressor
+		wantErr    error
+	}{
+		{name: "zero id", compressor: idCompressor(0), wantErr: errs.ErrInvalidCompressor},
+		{name: "collision", compressor: idCompressor(compressGzip), wantErr: errs.ErrInvalidCompressor},
+		{name: "builtin level", compressor: GzipCompressor{Level: 9}},
+		{name: "custom", compressor: idCompressor(10)},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			local := NewLocalCache()
+			defer local.Close()
+			_, err := NewCompressCache(local, WithCompressor(tc.compressor))
+			assert.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
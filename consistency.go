@@ -0,0 +1,123 @@
+package toycache
+
+import (
+	"context"
+	"github.com/aristletl/toycache/internal/errs"
+	"sync"
+	"time"
+)
+
+type ConsistencyHelperOption func(c *ConsistencyHelper)
+
+// ConsistencyHelper 实现延迟双删：更新数据库之后立刻删除缓存，
+// 过一段时间再删除一次，把并发读请求在这期间写回缓存的旧数据清理掉。
+// 删除失败会按照 RetryStrategy 重试，重试之后依旧失败的通过回调通知调用方
+type ConsistencyHelper struct {
+	cache    Cache
+	delay    time.Duration
+	newRetry func() RetryStrategy
+	onFailed func(key string, err error)
+
+	// mutex 保证 Close 之后不会再有新的第二次删除加入 wg
+	mutex     sync.Mutex
+	closed    bool
+	wg        sync.WaitGroup
+	close     chan struct{}
+	closeOnce sync.Once
+}
+
+// NewConsistencyHelper delay 是第二次删除的延迟时间，
+// 一般设置为一次读数据库并且写回缓存的耗时再多一点
+func NewConsistencyHelper(cache Cache, delay time.Duration, opts ...ConsistencyHelperOption) *ConsistencyHelper {
+	res := &ConsistencyHelper{
+		cache: cache,
+		delay: delay,
+		newRetry: func() RetryStrategy {
+			return NewFixIntervalRetry(100*time.Millisecond, 3)
+		},
+		close: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// WithDeleteRetry 设置删除失败时的重试策略，RetryStrategy 是有状态的，所以每次删除都会新建一个
+func WithDeleteRetry(fn func() RetryStrategy) ConsistencyHelperOption {
+	return func(c *ConsistencyHelper) {
+		c.newRetry = fn
+	}
+}
+
+// WithOnInvalidateFailed 设置重试之后依旧删除失败的回调，可以用来告警或者投递到消息队列补偿
+func WithOnInvalidateFailed(fn func(key string, err error)) ConsistencyHelperOption {
+	return func(c *ConsistencyHelper) {
+		c.onFailed = fn
+	}
+}
+
+// Invalidate 在更新数据库之后调用，立刻删除 key，并且在 delay 之后再删除一次。
+// 返回的是第一次删除的结果，第二次删除的结果只能通过回调拿到。
+// Close 之后直接返回 errs.ErrCacheClosed，不会删除 key
+func (c *ConsistencyHelper) Invalidate(ctx context.Context, key string) error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return errs.ErrCacheClosed
+	}
+	c.wg.Add(1)
+	c.mutex.Unlock()
+
+	err := c.delete(ctx, key)
+	if err != nil && c.onFailed != nil {
+		c.onFailed(key, err)
+	}
+
+	go func() {
+		defer c.wg.Done()
+		select {
+		case <-time.After(c.delay):
+		case <-c.close:
+			// 关闭的时候不再等待，立刻执行第二次删除
+		}
+		if er := c.delete(context.Background(), key); er != nil && c.onFailed != nil {
+			c.onFailed(key, er)
+		}
+	}()
+	return err
+}
+
+// Close 立刻执行所有还在等待的第二次删除，并等待它们结束
+func (c *ConsistencyHelper) Close() error {
+	c.mutex.Lock()
+	c.closed = true
+	c.mutex.Unlock()
+	c.closeOnce.Do(func() {
+		close(c.close)
+	})
+	c.wg.Wait()
+	return nil
+}
+
+func (c *ConsistencyHelper) delete(ctx context.Context, key string) error {
+	var retry RetryStrategy
+	if c.newRetry != nil {
+		retry = c.newRetry()
+	}
+	for {
+		err := c.cache.Delete(ctx, key)
+		if err == nil || retry == nil {
+			return err
+		}
+		interval, ok := retry.Next()
+		if !ok {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
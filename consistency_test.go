@@ -0,0 +1,148 @@
+package toycache
+
+import (
+	"context"
+	"errors"
+	"github.com/aristletl/toycache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// deleteRecorder 记录每一次删除的时间，前 fail 次删除返回错误
+type deleteRecorder struct {
+	Cache
+	mutex   sync.Mutex
+	fail    int
+	deletes []time.Time
+}
+
+var errDeleteFailed = errors.New("delete failed")
+
+func (d *deleteRecorder) Delete(ctx context.Context, key string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.deletes = append(d.deletes, time.Now())
+	if len(d.deletes) <= d.fail {
+		return errDeleteFailed
+	}
+	return nil
+}
+
+func (d *deleteRecorder) count() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return len(d.deletes)
+}
+
+func TestConsistencyHelper_Invalidate(t *testing.T) {
+	cache := &deleteRecorder{}
+	c := NewConsistencyHelper(cache, 50*time.Millisecond)
+	defer c.Close()
+
+	start := time.Now()
+	require.NoError(t, c.Invalidate(context.Background(), "key"))
+	// 第一次删除是同步的
+	assert.Equal(t, 1, cache.count())
+	require.Eventually(t, func() bool {
+		return cache.count() == 2
+	}, time.Second, time.Millisecond)
+	cache.mutex.Lock()
+	assert.GreaterOrEqual(t, cache.deletes[1].Sub(start), 50*time.Millisecond)
+	cache.mutex.Unlock()
+}
+
+func TestConsistencyHelper_Retry(t *testing.T) {
+	testCase := []struct {
+		name       string
+		fail       int
+		wantErr    error
+		wantFailed int
+		wantCount  int
+	}{
+		{
+			name:      "重试之后成功",
+			fail:      2,
+			wantCount: 4,
+		},
+		{
+			name:       "重试次数用完",
+			fail:       100,
+			wantErr:    errDeleteFailed,
+			wantFailed: 2,
+			wantCount:  6,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			cache := &deleteRecorder{fail: tc.fail}
+			var (
+				mutex  sync.Mutex
+				failed []string
+			)
+			c := NewConsistencyHelper(cache, time.Millisecond,
+				WithDeleteRetry(func() RetryStrategy {
+					return NewFixIntervalRetry(time.Millisecond, 3)
+				}),
+				WithOnInvalidateFailed(func(key string, err error) {
+					mutex.Lock()
+					defer mutex.Unlock()
+					assert.Equal(t, errDeleteFailed, err)
+					failed = append(failed, key)
+				}))
+
+			assert.Equal(t, tc.wantErr, c.Invalidate(context.Background(), "key"))
+			require.NoError(t, c.Close())
+			assert.Equal(t, tc.wantCount, cache.count())
+			mutex.Lock()
+			assert.Equal(t, tc.wantFailed, len(failed))
+			mutex.Unlock()
+		})
+	}
+}
+
+func TestConsistencyHelper_Close(t *testing.T) {
+	cache := &deleteRecorder{}
+	c := NewConsistencyHelper(cache, time.Hour)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, c.Invalidate(context.Background(), "key"))
+	}
+
+	// Close 不再等待 delay，立刻执行剩下的第二次删除
+	start := time.Now()
+	require.NoError(t, c.Close())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 6, cache.count())
+
+	assert.Equal(t, errs.ErrCacheClosed, c.Invalidate(context.Background(), "key"))
+	assert.Equal(t, 6, cache.count())
+	require.NoError(t, c.Close())
+}
+
+func TestConsistencyHelper_ConcurrentClose(t *testing.T) {
+	cache := &deleteRecorder{}
+	c := NewConsistencyHelper(cache, time.Millisecond)
+	var (
+		wg      sync.WaitGroup
+		mutex   sync.Mutex
+		success int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if c.Invalidate(context.Background(), "key") == nil {
+				mutex.Lock()
+				success++
+				mutex.Unlock()
+			}
+		}()
+	}
+	require.NoError(t, c.Close())
+	wg.Wait()
+	// 成功的调用两次删除都执行了
+	assert.Equal(t, 2*success, cache.count())
+}
@@ -0,0 +1,154 @@
+package toycache
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"github.com/aristletl/toycache/internal/errs"
+	"io"
+	"sync"
+	"time"
+)
+
+// encryptMagic 和 encryptVersion 组成密文头部的前两个字节，
+// 完整的格式是 magic + version + len(keyID) + keyID + nonce + 密文
+const (
+	encryptMagic   byte = 0xfd
+	encryptVersion byte = 1
+)
+
+// EncryptKey 是一个带 ID 的 AES 密钥，Key 的长度必须是 16、24 或者 32
+type EncryptKey struct {
+	ID  string
+	Key []byte
+}
+
+// EncryptCache 使用 AES-GCM 加密之后再写入 Cache。
+// 密文头部记录了加密使用的密钥 ID，所以轮换密钥之后老数据依旧可以解密，
+// 新数据总是使用最新的密钥加密。
+// 缓存的 key 会作为附加数据参与认证，密文被挪到别的 key 下面是无法解密的
+type EncryptCache struct {
+	Cache
+	mutex   sync.RWMutex
+	aeads   map[string]cipher.AEAD
+	current string
+}
+
+// NewEncryptCache 最后一个密钥会被用来加密，其余的密钥只用来解密
+func NewEncryptCache(cache Cache, keys ...EncryptKey) (*EncryptCache, error) {
+	res := &EncryptCache{
+		Cache: cache,
+		aeads: make(map[string]cipher.AEAD, len(keys)),
+	}
+	for _, key := range keys {
+		if err := res.Rotate(key); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Rotate 添加一个新的密钥，并且使用它来加密之后写入的数据
+func (e *EncryptCache) Rotate(key EncryptKey) error {
+	if len(key.ID) > 255 {
+		return errors.New("cache: 密钥 ID 不能超过 255 个字节")
+	}
+	block, err := aes.NewCipher(key.Key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.aeads[key.ID] = aead
+	e.current = key.ID
+	return nil
+}
+
+// Get 返回解密之后的 []byte
+func (e *EncryptCache) Get(ctx context.Context, key string) (any, error) {
+	val, err := e.Cache.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := toBytes(val)
+	if !ok {
+		return nil, errs.ErrInvalidCiphertext
+	}
+	return e.decrypt(key, data)
+}
+
+// Set 只接受 []byte 或者 string
+func (e *EncryptCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	data, ok := toBytes(val)
+	if !ok {
+		return errs.ErrUnsupportedValue
+	}
+	data, err := e.encrypt(key, data)
+	if err != nil {
+		return err
+	}
+	return e.Cache.Set(ctx, key, data, expiration)
+}
+
+// OnEvicted 回调拿到的是解密之后的值，解密失败的时候是 nil
+func (e *EncryptCache) OnEvicted(fn func(key string, val []byte)) {
+	e.Cache.OnEvicted(func(key string, val []byte) {
+		data, err := e.decrypt(key, val)
+		if err != nil {
+			data = nil
+		}
+		fn(key, data)
+	})
+}
+
+func (e *EncryptCache) encrypt(key string, data []byte) ([]byte, error) {
+	e.mutex.RLock()
+	id := e.current
+	aead, ok := e.aeads[id]
+	e.mutex.RUnlock()
+	if !ok {
+		return nil, errs.ErrNoEncryptKey
+	}
+
+	headerLen := 3 + len(id)
+	res := make([]byte, headerLen+aead.NonceSize(), headerLen+aead.NonceSize()+len(data)+aead.Overhead())
+	res[0], res[1], res[2] = encryptMagic, encryptVersion, byte(len(id))
+	copy(res[3:], id)
+	nonce := res[headerLen:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(res, nonce, data, []byte(key)), nil
+}
+
+func (e *EncryptCache) decrypt(key string, data []byte) ([]byte, error) {
+	if len(data) < 3 || data[0] != encryptMagic || data[1] != encryptVersion {
+		return nil, errs.ErrInvalidCiphertext
+	}
+	headerLen := 3 + int(data[2])
+	if len(data) < headerLen {
+		return nil, errs.ErrInvalidCiphertext
+	}
+	id := string(data[3:headerLen])
+	e.mutex.RLock()
+	aead, ok := e.aeads[id]
+	e.mutex.RUnlock()
+	if !ok {
+		return nil, errs.ErrUnknownEncryptKey
+	}
+	if len(data) < headerLen+aead.NonceSize() {
+		return nil, errs.ErrInvalidCiphertext
+	}
+	nonce := data[headerLen : headerLen+aead.NonceSize()]
+	res, err := aead.Open(nil, nonce, data[headerLen+aead.NonceSize():], []byte(key))
+	if err != nil {
+		return nil, errs.ErrInvalidCiphertext
+	}
+	return res, nil
+}
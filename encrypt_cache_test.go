@@ -0,0 +1,49 @@
+package toycache
+
+import (
+	"context"
+	"github.com/aristletl/toycache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestEncryptCache_Rotate(t *testing.T) {
+	ctx := context.Background()
+	local := NewLocalCache()
+	defer local.Close()
+	oldKey := EncryptKey{ID: "v1", Key: []byte("0123456789abcdef")}
+	newKey := EncryptKey{ID: "v2", Key: []byte("fedcba9876543210fedcba9876543210")}
+
+	c, err := NewEncryptCache(local, oldKey)
+	require.NoError(t, err)
+	require.NoError(t, c.Set(ctx, "old", "alice@example.com", time.Minute))
+
+	raw, err := local.Get(ctx, "old")
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw.([]byte)), "alice")
+
+	require.NoError(t, c.Rotate(newKey))
+	require.NoError(t, c.Set(ctx, "new", "bob@example.com", time.Minute))
+
+	val, err := c.Get(ctx, "old")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("alice@example.com"), val)
+	val, err = c.Get(ctx, "new")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("bob@example.com"), val)
+
+	// 只有旧密钥的实例无法解密新数据
+	onlyOld, err := NewEncryptCache(local, oldKey)
+	require.NoError(t, err)
+	_, err = onlyOld.Get(ctx, "new")
+	assert.Equal(t, errs.ErrUnknownEncryptKey, err)
+
+	// 密文换了 key 之后认证失败
+	raw, err = local.Get(ctx, "new")
+	require.NoError(t, err)
+	require.NoError(t, local.Set(ctx, "moved", raw, time.Minute))
+	_, err = c.Get(ctx, "moved")
+	assert.Equal(t, errs.ErrInvalidCiphertext, err)
+}
@@ -8,6 +8,18 @@ var (
 	ErrKeyNotFound    = errors.New("cache: 找不到key")
 	ErrFailedToSetKey = errors.New("cache: 设置失败")
 	ErrFailedToDelKey = errors.New("cache: 删除失败")
+	ErrTypeMismatch   = errors.New("cache: 值的类型不匹配")
+	ErrLoadFailed     = errors.New("cache: 加载数据失败")
+	ErrNotAdmitted    = errors.New("cache: 访问频率太低，拒绝写入")
+	ErrCacheClosed    = errors.New("cache: 缓存已经关闭")
+
+	ErrUnsupportedValue   = errors.New("cache: 只支持 []byte 或者 string 类型的值")
+	ErrUnknownCompression = errors.New("cache: 未知的压缩算法")
+	ErrInvalidCompressor  = errors.New("cache: 非法的压缩算法")
+
+	ErrNoEncryptKey      = errors.New("cache: 没有可用的加密密钥")
+	ErrUnknownEncryptKey = errors.New("cache: 找不到对应的解密密钥")
+	ErrInvalidCiphertext = errors.New("cache: 非法的密文")
 )
 
 var (
@@ -0,0 +1,56 @@
+package singleflight
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type call struct {
+	done chan struct{}
+	val  any
+	err  error
+}
+
+// Group 把同一个 key 的并发调用合并成一次，所有等待者共享同一个结果
+type Group struct {
+	mutex sync.Mutex
+	calls map[string]*call
+}
+
+// Do 发起或者加入 key 对应的调用。
+// fn 在独立的 goroutine 里执行，ctx 被取消只会让当前调用方提前返回，
+// 不会影响 fn 的执行以及其它等待者
+func (g *Group) Do(ctx context.Context, key string, fn func() (any, error)) (any, error) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	c, ok := g.calls[key]
+	if !ok {
+		c = &call{done: make(chan struct{})}
+		g.calls[key] = c
+		go g.doCall(c, key, fn)
+	}
+	g.mutex.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (g *Group) doCall(c *call, key string, fn func() (any, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("singleflight: panic: %v", r)
+		}
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn()
+}
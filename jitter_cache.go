@@ -0,0 +1,82 @@
+package toycache
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type JitterCacheOption func(j *JitterCache)
+
+// JitterCache 在写入的时候给过期时间加上一个随机的偏移量，
+// 避免同一批写入的 key 在同一时刻过期，导致缓存雪崩。
+// 偏移量在 [-delta, delta] 之间均匀分布，
+// delta 默认是过期时间的 10%
+type JitterCache struct {
+	Cache
+	percent float64
+	window  time.Duration
+
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+func NewJitterCache(cache Cache, opts ...JitterCacheOption) *JitterCache {
+	res := &JitterCache{
+		Cache:   cache,
+		percent: 0.1,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// WithJitterPercent delta 为过期时间乘以 percent，percent 的取值范围是 (0, 1)
+func WithJitterPercent(percent float64) JitterCacheOption {
+	return func(j *JitterCache) {
+		j.percent = percent
+		j.window = 0
+	}
+}
+
+// WithJitterWindow delta 固定为 window，和过期时间的长短无关
+func WithJitterWindow(window time.Duration) JitterCacheOption {
+	return func(j *JitterCache) {
+		j.window = window
+	}
+}
+
+// WithJitterSeed 固定随机数种子，测试的时候可以得到确定的结果
+func WithJitterSeed(seed int64) JitterCacheOption {
+	return func(j *JitterCache) {
+		j.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+func (j *JitterCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	return j.Cache.Set(ctx, key, val, j.jitter(expiration))
+}
+
+func (j *JitterCache) jitter(expiration time.Duration) time.Duration {
+	if expiration <= 0 {
+		return expiration
+	}
+	delta := j.window
+	if delta <= 0 {
+		delta = time.Duration(float64(expiration) * j.percent)
+	}
+	if delta <= 0 {
+		return expiration
+	}
+	j.mutex.Lock()
+	offset := time.Duration(j.rand.Int63n(int64(2*delta)+1)) - delta
+	j.mutex.Unlock()
+	// 偏移之后不能变成永不过期或者立刻过期
+	if res := expiration + offset; res > 0 {
+		return res
+	}
+	return expiration
+}
@@ -0,0 +1,86 @@
+package toycache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// expirationRecorder 记录写入时使用的过期时间
+type expirationRecorder struct {
+	Cache
+	expirations []time.Duration
+}
+
+func (e *expirationRecorder) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	e.expirations = append(e.expirations, expiration)
+	return nil
+}
+
+func TestJitterCache_Set(t *testing.T) {
+	testCase := []struct {
+		name        string
+		opts        []JitterCacheOption
+		expirations []time.Duration
+		want        []time.Duration
+	}{
+		{
+			name:        "按照比例",
+			opts:        []JitterCacheOption{WithJitterPercent(0.2)},
+			expirations: []time.Duration{time.Minute, time.Minute, time.Minute},
+			// delta 是 12s
+			want: []time.Duration{50087921202, 48518491947, 57869721163},
+		},
+		{
+			name:        "固定窗口",
+			opts:        []JitterCacheOption{WithJitterWindow(time.Second)},
+			expirations: []time.Duration{time.Minute, time.Minute, time.Minute},
+			want:        []time.Duration{59510988999, 59239482843, 60315987593},
+		},
+		{
+			name:        "偏移之后小于等于 0 使用原来的过期时间",
+			opts:        []JitterCacheOption{WithJitterWindow(time.Hour)},
+			expirations: []time.Duration{time.Millisecond, time.Millisecond, time.Millisecond},
+			want:        []time.Duration{time.Millisecond, time.Millisecond, 1138102104902},
+		},
+		{
+			name:        "永不过期和立刻过期原样传递",
+			expirations: []time.Duration{0, -time.Second},
+			want:        []time.Duration{0, -time.Second},
+		},
+		{
+			name:        "偏移量为 0",
+			opts:        []JitterCacheOption{WithJitterPercent(0.1)},
+			expirations: []time.Duration{time.Nanosecond},
+			want:        []time.Duration{time.Nanosecond},
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := &expirationRecorder{}
+			c := NewJitterCache(recorder, append(tc.opts, WithJitterSeed(42))...)
+			for _, exp := range tc.expirations {
+				require.NoError(t, c.Set(context.Background(), "key", "value", exp))
+			}
+			assert.Equal(t, tc.want, recorder.expirations)
+		})
+	}
+}
+
+func TestJitterCache_Spread(t *testing.T) {
+	recorder := &expirationRecorder{}
+	c := NewJitterCache(recorder, WithJitterSeed(1))
+	for i := 0; i < 100; i++ {
+		require.NoError(t, c.Set(context.Background(), "key", "value", time.Minute))
+	}
+	seen := make(map[time.Duration]struct{})
+	for _, exp := range recorder.expirations {
+		assert.True(t, exp >= 54*time.Second && exp <= 66*time.Second)
+		seen[exp] = struct{}{}
+	}
+	// 同一批写入的过期时间被打散了
+	assert.Greater(t, len(seen), 90)
+}
@@ -0,0 +1,173 @@
+package toycache
+
+import "sync"
+
+type LFUOption func(l *LFU)
+
+// LFU 淘汰访问次数最少的 key，访问次数相同的时候淘汰最久没有访问的。
+// 访问次数相同的 key 放在同一个桶里，桶按照访问次数从小到大串成链表，
+// 所以添加、访问、删除和淘汰都是 O(1) 的
+type LFU struct {
+	sync.Mutex
+	// head 和 tail 是桶链表的哨兵
+	head  *lfuBucket
+	tail  *lfuBucket
+	cache map[any]*lfuEntry
+
+	// aging 不为 0 的时候，每访问 aging 次就把所有的访问次数减半，
+	// 避免以前很热但是现在已经不再访问的 key 一直占着位置
+	aging    uint64
+	accesses uint64
+}
+
+type lfuBucket struct {
+	freq  uint64
+	items *linkedList
+	pre   *lfuBucket
+	next  *lfuBucket
+}
+
+type lfuEntry struct {
+	node   *node
+	bucket *lfuBucket
+}
+
+func NewLFU(opts ...LFUOption) *LFU {
+	head, tail := &lfuBucket{}, &lfuBucket{}
+	head.next = tail
+	tail.pre = head
+	res := &LFU{
+		head:  head,
+		tail:  tail,
+		cache: make(map[any]*lfuEntry),
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// WithLFUAging 每访问 interval 次就把所有 key 的访问次数减半
+func WithLFUAging(interval uint64) LFUOption {
+	return func(l *LFU) {
+		l.aging = interval
+	}
+}
+
+func (l *LFU) Get(key any) (AnyValue, bool) {
+	l.Lock()
+	defer l.Unlock()
+	e, ok := l.cache[key]
+	if !ok {
+		return AnyValue{}, false
+	}
+	return AnyValue{Val: e.node.value}, true
+}
+
+func (l *LFU) GetEliminatedKey() (any, AnyValue, bool) {
+	l.Lock()
+	defer l.Unlock()
+	if len(l.cache) == 0 {
+		return nil, AnyValue{}, false
+	}
+	n := l.head.next.items.back()
+	return n.key, AnyValue{Val: n.value}, true
+}
+
+// Add 添加 key 或者记录一次访问
+func (l *LFU) Add(key any, args ...any) {
+	if key == nil {
+		return
+	}
+	var val any
+	if len(args) != 0 {
+		val = args[0]
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	e, ok := l.cache[key]
+	if !ok {
+		e = &lfuEntry{node: &node{key: key, value: val}}
+		l.cache[key] = e
+		l.moveTo(e, l.head, 1)
+	} else {
+		e.node.value = val
+		l.moveTo(e, e.bucket, e.bucket.freq+1)
+	}
+
+	if l.aging > 0 {
+		l.accesses++
+		if l.accesses >= l.aging {
+			l.accesses = 0
+			l.decay()
+		}
+	}
+}
+
+func (l *LFU) Remove(key any) bool {
+	l.Lock()
+	defer l.Unlock()
+	e, ok := l.cache[key]
+	if !ok {
+		return false
+	}
+	delete(l.cache, key)
+	l.detach(e)
+	return true
+}
+
+// moveTo 把 e 移动到访问次数为 freq 的桶里，after 是 freq 前面的那个桶
+func (l *LFU) moveTo(e *lfuEntry, after *lfuBucket, freq uint64) {
+	target := after.next
+	if target == l.tail || target.freq != freq {
+		target = &lfuBucket{freq: freq, items: newLinkedList()}
+		target.pre, target.next = after, after.next
+		after.next.pre = target
+		after.next = target
+	}
+	if e.bucket != nil {
+		l.detach(e)
+	}
+	target.items.pushFront(e.node)
+	e.bucket = target
+}
+
+// detach 把 e 从所在的桶里移除，桶空了就把桶也移除
+func (l *LFU) detach(e *lfuEntry) {
+	b := e.bucket
+	b.items.remove(e.node)
+	e.bucket = nil
+	if b.items.len() == 0 {
+		b.pre.next = b.next
+		b.next.pre = b.pre
+	}
+}
+
+// decay 把所有的访问次数减半，最小为 1，减半之后相同次数的桶合并。
+// 从访问次数多的桶开始处理，合并的时候较旧的节点始终在链表尾部
+func (l *LFU) decay() {
+	for b := l.head.next; b != l.tail; b = b.next {
+		b.freq = b.freq / 2
+		if b.freq == 0 {
+			b.freq = 1
+		}
+	}
+	for b := l.tail.pre; b != l.head && b.pre != l.head; {
+		pre := b.pre
+		if pre.freq == b.freq {
+			// pre 里的节点更冷，按照原来的顺序接到 b 的尾部
+			for pre.items.len() > 0 {
+				n := pre.items.head.next
+				pre.items.remove(n)
+				insert(b.items.tail.pre, n)
+				b.items.size++
+				l.cache[n.key].bucket = b
+			}
+			pre.pre.next = b
+			b.pre = pre.pre
+			continue
+		}
+		b = pre
+	}
+}
@@ -0,0 +1,95 @@
+package toycache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLFU_GetEliminatedKey(t *testing.T) {
+	testCase := []struct {
+		name    string
+		cache   func() *LFU
+		wantKey any
+		wantOk  bool
+	}{
+		{
+			name: "没有数据",
+			cache: func() *LFU {
+				return NewLFU()
+			},
+		},
+		{
+			name: "淘汰访问次数最少的",
+			cache: func() *LFU {
+				l := NewLFU()
+				l.Add(1, 1)
+				l.Add(2, 2)
+				l.Add(1, 1)
+				l.Add(3, 3)
+				l.Add(3, 3)
+				return l
+			},
+			wantKey: 2,
+			wantOk:  true,
+		},
+		{
+			name: "访问次数相同淘汰最久没有访问的",
+			cache: func() *LFU {
+				l := NewLFU()
+				l.Add(1, 1)
+				l.Add(2, 2)
+				l.Add(3, 3)
+				l.Add(1, 1)
+				l.Add(2, 2)
+				return l
+			},
+			wantKey: 3,
+			wantOk:  true,
+		},
+		{
+			name: "删除之后",
+			cache: func() *LFU {
+				l := NewLFU()
+				l.Add(1, 1)
+				l.Add(2, 2)
+				l.Add(2, 2)
+				assert.True(t, l.Remove(1))
+				assert.False(t, l.Remove(1))
+				return l
+			},
+			wantKey: 2,
+			wantOk:  true,
+		},
+		{
+			name: "衰减之后旧的热点会被淘汰",
+			cache: func() *LFU {
+				l := NewLFU(WithLFUAging(8))
+				// 1 访问 5 次
+				for i := 0; i < 5; i++ {
+					l.Add(1, 1)
+				}
+				// 第 8 次访问之后衰减，1 的访问次数变成 2，2 变成 1
+				l.Add(2, 2)
+				l.Add(2, 2)
+				l.Add(2, 2)
+				// 2 和 3 的访问次数都是 3
+				l.Add(2, 2)
+				l.Add(2, 2)
+				l.Add(3, 3)
+				l.Add(3, 3)
+				l.Add(3, 3)
+				return l
+			},
+			wantKey: 1,
+			wantOk:  true,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			key, _, ok := tc.cache().GetEliminatedKey()
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantKey, key)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package toycache
+
+// linkedList 是带有头尾哨兵节点的双向链表，复用了 LRU 的 node，
+// 各种淘汰策略用它来维护队列。它不是并发安全的
+type linkedList struct {
+	head *node
+	tail *node
+	size int
+}
+
+func newLinkedList() *linkedList {
+	head, tail := new(node), new(node)
+	head.next = tail
+	tail.pre = head
+	return &linkedList{
+		head: head,
+		tail: tail,
+	}
+}
+
+func (l *linkedList) pushFront(n *node) {
+	insert(l.head, n)
+	l.size++
+}
+
+func (l *linkedList) remove(n *node) {
+	remove(n)
+	n.pre, n.next = nil, nil
+	l.size--
+}
+
+func (l *linkedList) moveToFront(n *node) {
+	remove(n)
+	insert(l.head, n)
+}
+
+// back 返回最后一个节点，链表为空的时候返回 nil
+func (l *linkedList) back() *node {
+	if l.size == 0 {
+		return nil
+	}
+	return l.tail.pre
+}
+
+func (l *linkedList) len() int {
+	return l.size
+}
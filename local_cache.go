@@ -1,6 +1,7 @@
 package toycache
 
 import (
+	"container/heap"
 	"context"
 	"github.com/aristletl/toycache/internal/errs"
 	"sync"
@@ -10,67 +11,102 @@ import (
 type LocalCacheOption func(l *LocalCache)
 
 type LocalCache struct {
+	localStore[string, any]
+}
+
+// localStore 是 LocalCache 和 TypedLocalCache 共用的实现，
+// 包括过期时间的小顶堆、清理过期数据的 goroutine 以及淘汰回调
+type localStore[K comparable, V any] struct {
 	sync.RWMutex
-	data      map[string]*item
-	close     chan struct{}
-	closeOnce sync.Once
+	data map[K]*item[K, V]
+	// expiries 按照过期时间排序的小顶堆，清理的时候只需要从堆顶开始弹出
+	expiries      expiryHeap[K, V]
+	sweepInterval time.Duration
+	close         chan struct{}
+	closeOnce     sync.Once
 
-	onEvicted func(key string, val any)
+	// onEvicted 在持有锁的时候调用，回调里面不能再操作这个缓存
+	onEvicted []func(key K, val V, reason EvictReason)
 }
 
+// OnEvicted 注册淘汰的回调，val 不是 []byte 或者 string 的时候回调拿到的是 nil。
+// 覆盖写不会触发这个回调，因为 key 还在缓存里，需要区分原因的使用 OnEvictedWithReason
 func (l *LocalCache) OnEvicted(fn func(key string, val []byte)) {
-	panic("implement me")
+	l.OnEvictedWithReason(func(key string, val any, reason EvictReason) {
+		if reason == EvictReasonReplaced {
+			return
+		}
+		data, _ := toBytes(val)
+		fn(key, data)
+	})
 }
 
-func NewLocalCache(opts ...LocalCacheOption) *LocalCache {
-	l := &LocalCache{
-		data:  make(map[string]*item),
-		close: make(chan struct{}, 1),
-	}
+// OnEvictedWithReason 注册带有淘汰原因的回调，覆盖写的时候 val 是旧值
+func (l *localStore[K, V]) OnEvictedWithReason(fn func(key K, val V, reason EvictReason)) {
+	l.Lock()
+	defer l.Unlock()
+	l.onEvicted = append(l.onEvicted, fn)
+}
 
+func NewLocalCache(opts ...LocalCacheOption) *LocalCache {
+	l := &LocalCache{}
+	l.init()
 	for _, opt := range opts {
 		opt(l)
 	}
+	l.start()
+	return l
+}
 
-	timer := time.NewTicker(time.Second)
+func (l *localStore[K, V]) init() {
+	l.data = make(map[K]*item[K, V])
+	l.sweepInterval = time.Second
+	l.close = make(chan struct{}, 1)
+}
+
+// start 启动清理过期数据的 goroutine，需要在应用完 option 之后调用
+func (l *localStore[K, V]) start() {
+	if l.sweepInterval <= 0 {
+		l.sweepInterval = time.Second
+	}
+	timer := time.NewTicker(l.sweepInterval)
 	go func() {
 		for {
 			select {
 			case <-timer.C:
-				cnt := 0
 				l.Lock()
-				for k, v := range l.data {
-					if v.deadline.Before(time.Now()) {
-						l.delete(k, v.val)
-					}
-					cnt++
-					if cnt >= 1000 {
-						break
-					}
+				now := time.Now()
+				for len(l.expiries) > 0 && l.expiries[0].deadline.Before(now) {
+					l.delete(l.expiries[0], EvictReasonExpired)
 				}
 				l.Unlock()
 			case <-l.close:
+				timer.Stop()
 				return
 			}
 		}
 	}()
+}
 
-	return l
+func (l *localStore[K, V]) delete(itm *item[K, V], reason EvictReason) {
+	delete(l.data, itm.key)
+	heap.Remove(&l.expiries, itm.index)
+	l.notify(itm, reason)
 }
 
-func (l *LocalCache) delete(key string, val any) {
-	delete(l.data, key)
-	if l.onEvicted != nil {
-		l.onEvicted(key, val)
+func (l *localStore[K, V]) notify(itm *item[K, V], reason EvictReason) {
+	for _, fn := range l.onEvicted {
+		fn(itm.key, itm.val, reason)
 	}
 }
 
-func (l *LocalCache) Get(ctx context.Context, key string) (any, error) {
+func (l *localStore[K, V]) Get(ctx context.Context, key K) (V, error) {
+	var zero V
 	l.RLock()
 	val, ok := l.data[key]
 	l.RUnlock()
 	if !ok {
-		return nil, errs.ErrKeyNotFound
+		return zero, errs.ErrKeyNotFound
 	}
 
 	// double check
@@ -80,38 +116,74 @@ func (l *LocalCache) Get(ctx context.Context, key string) (any, error) {
 		defer l.Unlock()
 		val, ok = l.data[key]
 		if !ok {
-			return nil, errs.ErrKeyNotFound
+			return zero, errs.ErrKeyNotFound
 		}
 		if val.deadline.Before(now) {
-			l.delete(key, val.val)
-			return nil, errs.ErrKeyNotFound
+			l.delete(val, EvictReasonExpired)
+			return zero, errs.ErrKeyNotFound
 		}
 	}
 	return val.val, nil
 }
 
-func (l *LocalCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+func (l *localStore[K, V]) Set(ctx context.Context, key K, val V, expiration time.Duration) error {
 	l.Lock()
 	defer l.Unlock()
-	l.data[key] = &item{
+	// item 写入之后就不再修改，Get 在锁外面读取 item 是安全的
+	itm := &item[K, V]{
+		key:      key,
 		val:      val,
 		deadline: time.Now().Add(expiration),
 	}
+	old, ok := l.data[key]
+	if ok {
+		itm.index = old.index
+		l.expiries[itm.index] = itm
+		heap.Fix(&l.expiries, itm.index)
+	} else {
+		heap.Push(&l.expiries, itm)
+	}
+	l.data[key] = itm
+	if ok {
+		l.notify(old, EvictReasonReplaced)
+	}
 	return nil
 }
 
-func (l *LocalCache) Delete(ctx context.Context, key string) error {
+func (l *localStore[K, V]) Delete(ctx context.Context, key K) error {
+	return l.remove(key, EvictReasonDeleted)
+}
+
+// Evict 因为容量不足淘汰 key，和 Delete 的区别只在于回调拿到的原因
+func (l *localStore[K, V]) Evict(ctx context.Context, key K) error {
+	return l.remove(key, EvictReasonCapacity)
+}
+
+func (l *localStore[K, V]) remove(key K, reason EvictReason) error {
 	l.Lock()
 	defer l.Unlock()
 	val, ok := l.data[key]
 	if !ok {
 		return nil
 	}
-	l.delete(key, val.val)
+	l.delete(val, reason)
 	return nil
 }
 
-func (l *LocalCache) Close() error {
+// Clear 删除所有的 key，回调拿到的原因是 EvictReasonDeleted
+func (l *localStore[K, V]) Clear(ctx context.Context) error {
+	l.Lock()
+	defer l.Unlock()
+	items := l.expiries
+	l.data = make(map[K]*item[K, V])
+	l.expiries = nil
+	for _, itm := range items {
+		l.notify(itm, EvictReasonDeleted)
+	}
+	return nil
+}
+
+func (l *localStore[K, V]) Close() error {
 	l.closeOnce.Do(func() {
 		l.close <- struct{}{}
 		close(l.close)
@@ -119,13 +191,67 @@ func (l *LocalCache) Close() error {
 	return nil
 }
 
+// WithOnEvicted 和 OnEvicted 一样，覆盖写不会触发回调
 func WithOnEvicted(fn func(key string, val any)) LocalCacheOption {
 	return func(l *LocalCache) {
-		l.onEvicted = fn
+		l.onEvicted = append(l.onEvicted, func(key string, val any, reason EvictReason) {
+			if reason != EvictReasonReplaced {
+				fn(key, val)
+			}
+		})
+	}
+}
+
+// WithOnEvictedReason 和 OnEvictedWithReason 一样
+func WithOnEvictedReason(fn func(key string, val any, reason EvictReason)) LocalCacheOption {
+	return func(l *LocalCache) {
+		l.onEvicted = append(l.onEvicted, fn)
+	}
+}
+
+// WithSweepInterval 设置清理过期数据的时间间隔，默认是一秒，小于等于 0 的时候也使用默认值
+func WithSweepInterval(interval time.Duration) LocalCacheOption {
+	return func(l *LocalCache) {
+		l.sweepInterval = interval
 	}
 }
 
-type item struct {
-	val      any
+type item[K comparable, V any] struct {
+	key      K
+	val      V
 	deadline time.Time
+	// index 是 item 在 expiries 中的下标
+	index int
+}
+
+// expiryHeap 实现了 heap.Interface
+type expiryHeap[K comparable, V any] []*item[K, V]
+
+func (h expiryHeap[K, V]) Len() int {
+	return len(h)
+}
+
+func (h expiryHeap[K, V]) Less(i, j int) bool {
+	return h[i].deadline.Before(h[j].deadline)
+}
+
+func (h expiryHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap[K, V]) Push(x any) {
+	itm := x.(*item[K, V])
+	itm.index = len(*h)
+	*h = append(*h, itm)
+}
+
+func (h *expiryHeap[K, V]) Pop() any {
+	old := *h
+	n := len(old)
+	itm := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return itm
 }
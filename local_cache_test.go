@@ -0,0 +1,106 @@
+package toycache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLocalCache_Sweep(t *testing.T) {
+	ctx := context.Background()
+	c := NewLocalCache(WithSweepInterval(10 * time.Millisecond))
+	defer c.Close()
+
+	for i := 0; i < 5000; i++ {
+		expiration := time.Millisecond
+		if i%2 == 0 {
+			expiration = time.Hour
+		}
+		assert.NoError(t, c.Set(ctx, strconv.Itoa(i), i, expiration))
+	}
+	// 覆盖之后过期时间也要跟着更新
+	assert.NoError(t, c.Set(ctx, "1", 1, time.Hour))
+	assert.NoError(t, c.Set(ctx, "0", 0, time.Millisecond))
+
+	assert.Eventually(t, func() bool {
+		c.RLock()
+		defer c.RUnlock()
+		return len(c.data) == 2500 && len(c.expiries) == 2500
+	}, time.Second, 10*time.Millisecond)
+
+	_, err := c.Get(ctx, "1")
+	assert.NoError(t, err)
+	_, err = c.Get(ctx, "0")
+	assert.Error(t, err)
+}
+
+func TestLocalCache_OnEvicted(t *testing.T) {
+	type evicted struct {
+		key    string
+		val    any
+		reason EvictReason
+	}
+	ctx := context.Background()
+	var (
+		reasons []evicted
+		plain   []string
+	)
+	c := NewLocalCache(WithOnEvictedReason(func(key string, val any, reason EvictReason) {
+		reasons = append(reasons, evicted{key: key, val: val, reason: reason})
+	}))
+	defer c.Close()
+	c.OnEvicted(func(key string, val []byte) {
+		plain = append(plain, key+"="+string(val))
+	})
+
+	assert.NoError(t, c.Set(ctx, "replaced", "v1", time.Minute))
+	assert.NoError(t, c.Set(ctx, "replaced", "v2", time.Minute))
+	assert.NoError(t, c.Set(ctx, "deleted", "v", time.Minute))
+	assert.NoError(t, c.Delete(ctx, "deleted"))
+	assert.NoError(t, c.Set(ctx, "capacity", "v", time.Minute))
+	assert.NoError(t, c.Evict(ctx, "capacity"))
+	assert.NoError(t, c.Set(ctx, "expired", "v", time.Millisecond))
+	time.Sleep(2 * time.Millisecond)
+	_, err := c.Get(ctx, "expired")
+	assert.Error(t, err)
+
+	assert.Equal(t, []evicted{
+		{key: "replaced", val: "v1", reason: EvictReasonReplaced},
+		{key: "deleted", val: "v", reason: EvictReasonDeleted},
+		{key: "capacity", val: "v", reason: EvictReasonCapacity},
+		{key: "expired", val: "v", reason: EvictReasonExpired},
+	}, reasons)
+	// 覆盖写不会触发 OnEvicted
+	assert.Equal(t, []string{"deleted=v", "capacity=v", "expired=v"}, plain)
+}
+
+func TestLocalCache_Clear(t *testing.T) {
+	ctx := context.Background()
+	var evicted []string
+	c := NewLocalCache(WithOnEvictedReason(func(key string, val any, reason EvictReason) {
+		assert.Equal(t, EvictReasonDeleted, reason)
+		evicted = append(evicted, key)
+	}))
+	defer c.Close()
+	assert.NoError(t, c.Set(ctx, "a", 1, time.Minute))
+	assert.NoError(t, c.Set(ctx, "b", 2, time.Minute))
+
+	assert.NoError(t, c.Clear(ctx))
+	assert.ElementsMatch(t, []string{"a", "b"}, evicted)
+	_, err := c.Get(ctx, "a")
+	assert.Error(t, err)
+	// 清空之后可以继续使用
+	assert.NoError(t, c.Set(ctx, "a", 1, time.Minute))
+	_, err = c.Get(ctx, "a")
+	assert.NoError(t, err)
+}
+
+func TestLocalCache_InvalidSweepInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		c := NewLocalCache(WithSweepInterval(interval))
+		assert.Equal(t, time.Second, c.sweepInterval)
+		assert.NoError(t, c.Close())
+	}
+}
@@ -2,45 +2,139 @@ package toycache
 
 import (
 	"context"
+	"github.com/aristletl/toycache/internal/errs"
 	"log"
-	"sync/atomic"
+	"sync"
 	"time"
 )
 
+type MaxMemoryCacheOption func(m *MaxMemoryCache)
+
+// MaxMemoryCache 限制缓存的总成本，写入之后超过 max 的时候按照 EliminateStrategy 淘汰，
+// 直到总成本不超过 safeLine。成本由 Weigher 计算，默认是 DefaultWeigher
 type MaxMemoryCache struct {
 	Cache
 	max         int64
 	safeLine    int64
-	used        int64
 	elimination EliminateStrategy
+	weigher     Weigher
+
+	// mutex 保护 used 和 sizes，Get 只需要读锁
+	mutex sync.RWMutex
+	used  int64
+	// sizes 记录每个 key 的成本。淘汰回调和主动淘汰都会扣减，
+	// 有了它扣减就是幂等的，RedisCache 这种异步回调的实现也不会重复扣减
+	sizes map[string]int64
 }
 
-func NewMaxMemoryCache(max, safeLine int64, e EliminateStrategy, cache Cache) *MaxMemoryCache {
+func NewMaxMemoryCache(max, safeLine int64, e EliminateStrategy, cache Cache,
+	opts ...MaxMemoryCacheOption) *MaxMemoryCache {
 	res := &MaxMemoryCache{
 		max:         max,
 		safeLine:    safeLine,
 		Cache:       cache,
 		elimination: e,
+		weigher:     DefaultWeigher,
+		sizes:       make(map[string]int64),
+	}
+	for _, opt := range opts {
+		opt(res)
 	}
 	res.Cache.OnEvicted(func(key string, val []byte) {
 		// 注册回调
-		ok := res.elimination.Remove(key)
-		if ok {
-			atomic.AddInt64(&res.used, -int64(len(val)))
-		}
+		res.release(key)
 	})
 	return res
 }
 
-func (m *MaxMemoryCache) Set(ctx context.Context, key string, val []byte,
+// WithWeigher 设置计算成本的函数，max 和 safeLine 的单位和它保持一致
+func WithWeigher(weigher Weigher) MaxMemoryCacheOption {
+	return func(m *MaxMemoryCache) {
+		m.weigher = weigher
+	}
+}
+
+// evictor 是能够以容量不足的原因淘汰 key 的 Cache，例如 LocalCache
+type evictor interface {
+	Evict(ctx context.Context, key string) error
+}
+
+// evict 优先使用 Evict，这样回调拿到的原因是 EvictReasonCapacity
+func (m *MaxMemoryCache) evict(ctx context.Context, key string) error {
+	var err error
+	if e, ok := m.Cache.(evictor); ok {
+		err = e.Evict(ctx, key)
+	} else {
+		err = m.Delete(ctx, key)
+	}
+	if err != nil {
+		return err
+	}
+	m.release(key)
+	// key 可能已经不在 sizes 里了，也要从淘汰策略里移除，否则会被反复选中
+	m.elimination.Remove(key)
+	return nil
+}
+
+func (m *MaxMemoryCache) release(key string) {
+	m.mutex.Lock()
+	size, ok := m.sizes[key]
+	if ok {
+		delete(m.sizes, key)
+		m.used -= size
+	}
+	m.mutex.Unlock()
+	if ok {
+		m.elimination.Remove(key)
+	}
+}
+
+// admit 新的 key 需要淘汰别的 key 才能写入的时候，询问淘汰策略是否值得写入
+func (m *MaxMemoryCache) admit(key string) bool {
+	a, ok := m.elimination.(Admitter)
+	if !ok {
+		return true
+	}
+	m.mutex.RLock()
+	_, exist := m.sizes[key]
+	m.mutex.RUnlock()
+	return exist || a.Admit(key)
+}
+
+// usage 返回写入 key 之后的总成本，覆盖写的时候旧值的成本不计算在内
+func (m *MaxMemoryCache) usage(key string, size int64) int64 {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.used - m.sizes[key] + size
+}
+
+// Get 命中之后通知淘汰策略，LFU 之类的策略需要知道 key 的访问情况
+func (m *MaxMemoryCache) Get(ctx context.Context, key string) (any, error) {
+	val, err := m.Cache.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	m.mutex.RLock()
+	size, ok := m.sizes[key]
+	m.mutex.RUnlock()
+	if ok {
+		m.elimination.Add(key, size)
+	}
+	return val, nil
+}
+
+func (m *MaxMemoryCache) Set(ctx context.Context, key string, val any,
 	expiration time.Duration) error {
-	// 在这里判断内存使用量，以及腾出空间
-	valSize := int64(len(val))
-	if atomic.LoadInt64(&m.used)+valSize > m.max {
-		for atomic.LoadInt64(&m.used)+valSize > m.safeLine {
+	// 在这里判断总成本，以及腾出空间
+	valSize := m.weigher(key, val)
+	if m.usage(key, valSize) > m.max {
+		if !m.admit(key) {
+			return errs.ErrNotAdmitted
+		}
+		for m.usage(key, valSize) > m.safeLine {
 			k, _, ok := m.elimination.GetEliminatedKey()
 			if ok {
-				err := m.Delete(ctx, k.(string))
+				err := m.evict(ctx, k.(string))
 				if err != nil {
 					return err
 				}
@@ -50,7 +144,18 @@ func (m *MaxMemoryCache) Set(ctx context.Context, key string, val []byte,
 			}
 		}
 	}
-	m.elimination.Add(key, valSize)
-	atomic.AddInt64(&m.used, valSize)
-	return m.Cache.Set(ctx, key, val, expiration)
+	// 写入成功之后才记账，否则失败的写入会在淘汰策略里留下不存在的 key
+	if err := m.Cache.Set(ctx, key, val, expiration); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	m.used += valSize - m.sizes[key]
+	m.sizes[key] = valSize
+	m.mutex.Unlock()
+	var deadline time.Time
+	if expiration > 0 {
+		deadline = time.Now().Add(expiration)
+	}
+	m.elimination.Add(key, valSize, deadline)
+	return nil
 }
@@ -0,0 +1,64 @@
+package toycache
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMaxMemoryCache_LocalCache(t *testing.T) {
+	ctx := context.Background()
+	local := NewLocalCache()
+	defer local.Close()
+	c := NewMaxMemoryCache(10, 6, NewLRU(), local)
+
+	assert.NoError(t, c.Set(ctx, "a", []byte("1234"), time.Minute))
+	// 覆盖写只统计新值的大小
+	assert.NoError(t, c.Set(ctx, "a", []byte("12"), time.Minute))
+	assert.Equal(t, int64(2), c.used)
+	assert.NoError(t, c.Set(ctx, "b", []byte("1234"), time.Minute))
+	assert.NoError(t, c.Set(ctx, "c", []byte("12345"), time.Minute))
+
+	_, err := local.Get(ctx, "a")
+	assert.Error(t, err)
+	_, err = local.Get(ctx, "c")
+	assert.NoError(t, err)
+}
+
+func TestMaxMemoryCache_Weigher(t *testing.T) {
+	ctx := context.Background()
+	local := NewLocalCache()
+	defer local.Close()
+	// 每个 key 的成本都是 1，容量就是 key 的数量
+	c := NewMaxMemoryCache(2, 2, NewLRU(), local, WithWeigher(func(key string, val any) int64 {
+		return 1
+	}))
+
+	assert.NoError(t, c.Set(ctx, "a", struct{ Name string }{Name: "Tom"}, time.Minute))
+	assert.NoError(t, c.Set(ctx, "b", []int{1, 2, 3}, time.Minute))
+	assert.NoError(t, c.Set(ctx, "c", "hello", time.Minute))
+	assert.Equal(t, int64(2), c.used)
+	_, err := local.Get(ctx, "a")
+	assert.Error(t, err)
+	val, err := c.Get(ctx, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, val)
+}
+
+func TestMaxMemoryCache_SetFailed(t *testing.T) {
+	ctx := context.Background()
+	errSet := errors.New("set failed")
+	lru := NewLRU()
+	c := NewMaxMemoryCache(30, 30, lru, failingCache{err: errSet})
+
+	for _, key := range []string{"a", "b", "c"} {
+		assert.Equal(t, errSet, c.Set(ctx, key, make([]byte, 10), time.Minute))
+	}
+	// 写入失败的 key 不计入成本，也不会进入淘汰策略
+	assert.Equal(t, int64(0), c.used)
+	assert.Empty(t, c.sizes)
+	_, _, ok := lru.GetEliminatedKey()
+	assert.False(t, ok)
+}
@@ -0,0 +1,131 @@
+package toycache
+
+import (
+	"context"
+	"github.com/aristletl/toycache/internal/errs"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+type MultiLevelCacheOption func(m *MultiLevelCache)
+
+// MultiLevelCache 是两级缓存，一般 l1 是 LocalCache，l2 是 RedisCache。
+// 读的时候先读 l1，未命中再读 l2 并回写 l1；写和删除会同时作用于两级缓存
+type MultiLevelCache struct {
+	l1 Cache
+	l2 Cache
+
+	// l1Expiration 是 l1 中数据的最长过期时间
+	l1Expiration time.Duration
+	// degrade 为 true 的时候，l2 出错只打印日志，退化成只使用 l1
+	degrade bool
+
+	l1Hits   int64
+	l1Misses int64
+	l2Hits   int64
+	l2Misses int64
+}
+
+// MultiLevelStats 是各级缓存的命中统计
+type MultiLevelStats struct {
+	L1Hits   int64
+	L1Misses int64
+	L2Hits   int64
+	L2Misses int64
+}
+
+func NewMultiLevelCache(l1, l2 Cache, opts ...MultiLevelCacheOption) *MultiLevelCache {
+	res := &MultiLevelCache{
+		l1:           l1,
+		l2:           l2,
+		l1Expiration: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// WithL1Expiration 设置 l1 的过期时间，写入 l1 的时候取它和传入的过期时间中较小的一个
+func WithL1Expiration(expiration time.Duration) MultiLevelCacheOption {
+	return func(m *MultiLevelCache) {
+		m.l1Expiration = expiration
+	}
+}
+
+// WithL2Degrade l2 出错的时候不返回错误，退化成只使用 l1
+func WithL2Degrade() MultiLevelCacheOption {
+	return func(m *MultiLevelCache) {
+		m.degrade = true
+	}
+}
+
+func (m *MultiLevelCache) Get(ctx context.Context, key string) (any, error) {
+	val, err := m.l1.Get(ctx, key)
+	if err == nil {
+		atomic.AddInt64(&m.l1Hits, 1)
+		return val, nil
+	}
+	atomic.AddInt64(&m.l1Misses, 1)
+
+	val, err = m.l2.Get(ctx, key)
+	switch {
+	case err == nil:
+		atomic.AddInt64(&m.l2Hits, 1)
+	case err == errs.ErrKeyNotFound:
+		atomic.AddInt64(&m.l2Misses, 1)
+		return nil, err
+	case m.degrade:
+		log.Printf("cache: 读取二级缓存失败, key %s, err: %v", key, err)
+		return nil, errs.ErrKeyNotFound
+	default:
+		return nil, err
+	}
+
+	if er := m.l1.Set(ctx, key, val, m.l1Expiration); er != nil {
+		log.Printf("cache: 回写一级缓存失败, key %s, err: %v", key, er)
+	}
+	return val, nil
+}
+
+func (m *MultiLevelCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	if err := m.l2.Set(ctx, key, val, expiration); err != nil {
+		if !m.degrade {
+			return err
+		}
+		log.Printf("cache: 写入二级缓存失败, key %s, err: %v", key, err)
+	}
+	l1Expiration := expiration
+	if l1Expiration > m.l1Expiration {
+		l1Expiration = m.l1Expiration
+	}
+	return m.l1.Set(ctx, key, val, l1Expiration)
+}
+
+// Delete 无论 l2 是否删除成功，都会删除 l1，避免 l1 中留下旧数据
+func (m *MultiLevelCache) Delete(ctx context.Context, key string) error {
+	err := m.l2.Delete(ctx, key)
+	if er := m.l1.Delete(ctx, key); er != nil {
+		return er
+	}
+	if err != nil && m.degrade {
+		log.Printf("cache: 删除二级缓存失败, key %s, err: %v", key, err)
+		return nil
+	}
+	return err
+}
+
+// OnEvicted 只关注 l2 的淘汰，l1 的淘汰并不意味着数据离开了缓存
+func (m *MultiLevelCache) OnEvicted(fn func(key string, val []byte)) {
+	m.l2.OnEvicted(fn)
+}
+
+func (m *MultiLevelCache) Stats() MultiLevelStats {
+	return MultiLevelStats{
+		L1Hits:   atomic.LoadInt64(&m.l1Hits),
+		L1Misses: atomic.LoadInt64(&m.l1Misses),
+		L2Hits:   atomic.LoadInt64(&m.l2Hits),
+		L2Misses: atomic.LoadInt64(&m.l2Misses),
+	}
+}
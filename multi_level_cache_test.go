@@ -0,0 +1,94 @@
+package toycache
+
+import (
+	"context"
+	"errors"
+	"github.com/aristletl/toycache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMultiLevelCache_Get(t *testing.T) {
+	ctx := context.Background()
+	l1, l2 := NewLocalCache(), NewLocalCache()
+	defer l1.Close()
+	defer l2.Close()
+	c := NewMultiLevelCache(l1, l2, WithL1Expiration(time.Second))
+
+	assert.NoError(t, l2.Set(ctx, "key", "value", time.Minute))
+	val, err := c.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+
+	// 已经回写到 l1
+	val, err = l1.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+	val, err = c.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+
+	_, err = c.Get(ctx, "absent")
+	assert.Equal(t, errs.ErrKeyNotFound, err)
+
+	assert.Equal(t, MultiLevelStats{L1Hits: 1, L1Misses: 2, L2Hits: 1, L2Misses: 1}, c.Stats())
+
+	assert.NoError(t, c.Delete(ctx, "key"))
+	_, err = l1.Get(ctx, "key")
+	assert.Equal(t, errs.ErrKeyNotFound, err)
+	_, err = l2.Get(ctx, "key")
+	assert.Equal(t, errs.ErrKeyNotFound, err)
+}
+
+func TestMultiLevelCache_L2Failure(t *testing.T) {
+	ctx := context.Background()
+	errL2 := errors.New("l2 down")
+	testCase := []struct {
+		name       string
+		opts       []MultiLevelCacheOption
+		wantSetErr error
+		wantGetErr error
+	}{
+		{
+			name:       "返回错误",
+			wantSetErr: errL2,
+			wantGetErr: errL2,
+		},
+		{
+			name:       "退化成只使用 l1",
+			opts:       []MultiLevelCacheOption{WithL2Degrade()},
+			wantGetErr: errs.ErrKeyNotFound,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			l1 := NewLocalCache()
+			defer l1.Close()
+			c := NewMultiLevelCache(l1, failingCache{err: errL2}, tc.opts...)
+			assert.Equal(t, tc.wantSetErr, c.Set(ctx, "key", "value", time.Minute))
+			_, err := c.Get(ctx, "absent")
+			assert.Equal(t, tc.wantGetErr, err)
+		})
+	}
+}
+
+// failingCache 所有的操作都返回 err
+type failingCache struct {
+	err error
+}
+
+func (f failingCache) Get(ctx context.Context, key string) (any, error) {
+	return nil, f.err
+}
+
+func (f failingCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	return f.err
+}
+
+func (f failingCache) Delete(ctx context.Context, key string) error {
+	return f.err
+}
+
+func (f failingCache) OnEvicted(fn func(key string, val []byte)) {}
@@ -0,0 +1,232 @@
+package toycache
+
+import (
+	"bytes"
+	"context"
+	"github.com/aristletl/toycache/internal/errs"
+	"github.com/aristletl/toycache/internal/singleflight"
+	"log"
+	"time"
+)
+
+// LoadFunc 在缓存未命中的时候从数据源加载数据
+type LoadFunc func(ctx context.Context, key string) (any, error)
+
+// loadErrorPolicy 决定 LoadFunc 返回错误的时候怎么处理
+type loadErrorPolicy uint8
+
+const (
+	// loadErrorPropagate 直接把错误返回给调用方
+	loadErrorPropagate loadErrorPolicy = iota
+	// loadErrorCacheNegative 把失败的结果缓存一段时间，期间不会再调用 LoadFunc
+	loadErrorCacheNegative
+	// loadErrorServeStale 返回上一次加载成功的值
+	loadErrorServeStale
+)
+
+// staleKeySuffix 用于保存旧值的 key 的后缀
+const staleKeySuffix = "#stale"
+
+// negativeEntry 是写入缓存的负缓存标记。
+// LocalCache 直接保存这个结构体，RedisCache 则会把它编码成 MarshalBinary 返回的标记，
+// 标记以 "\x00\xff" 开头，JSON、gob 编码的结果以及正常的文本都不会以它开头
+type negativeEntry struct {
+	// notFound 为 true 表示数据源里没有这个 key，否则表示加载失败
+	notFound bool
+}
+
+var (
+	notFoundMarker   = []byte("\x00\xfftoycache:not-found")
+	loadFailedMarker = []byte("\x00\xfftoycache:load-failed")
+)
+
+func (n negativeEntry) MarshalBinary() ([]byte, error) {
+	if n.notFound {
+		return notFoundMarker, nil
+	}
+	return loadFailedMarker, nil
+}
+
+func (n negativeEntry) err() error {
+	if n.notFound {
+		return errs.ErrKeyNotFound
+	}
+	return errs.ErrLoadFailed
+}
+
+// parseNegativeEntry 识别内存中的标记以及从 Redis 读出来的编码后的标记
+func parseNegativeEntry(val any) (negativeEntry, bool) {
+	var data []byte
+	switch v := val.(type) {
+	case negativeEntry:
+		return v, true
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return negativeEntry{}, false
+	}
+	switch {
+	case bytes.Equal(data, notFoundMarker):
+		return negativeEntry{notFound: true}, true
+	case bytes.Equal(data, loadFailedMarker):
+		return negativeEntry{}, true
+	default:
+		return negativeEntry{}, false
+	}
+}
+
+type ReadThroughCacheOption func(r *ReadThroughCache)
+
+// ReadThroughCache 在缓存未命中的时候调用 LoadFunc 加载数据，
+// 写入缓存之后再返回给调用方
+type ReadThroughCache struct {
+	Cache
+	loadFunc   LoadFunc
+	expiration time.Duration
+
+	policy             loadErrorPolicy
+	negativeExpiration time.Duration
+	staleExpiration    time.Duration
+	notFoundExpiration time.Duration
+
+	// group 不为 nil 的时候，同一个 key 的并发加载会被合并成一次
+	group *singleflight.Group
+}
+
+func NewReadThroughCache(cache Cache, loadFunc LoadFunc, expiration time.Duration,
+	opts ...ReadThroughCacheOption) *ReadThroughCache {
+	res := &ReadThroughCache{
+		Cache:      cache,
+		loadFunc:   loadFunc,
+		expiration: expiration,
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// WithCacheLoadError 加载失败之后，在 expiration 内直接返回 errs.ErrLoadFailed，
+// 避免数据源出问题的时候还被持续请求
+func WithCacheLoadError(expiration time.Duration) ReadThroughCacheOption {
+	return func(r *ReadThroughCache) {
+		r.policy = loadErrorCacheNegative
+		r.negativeExpiration = expiration
+	}
+}
+
+// WithServeStale 加载失败的时候返回上一次加载成功的值。
+// 旧值保存在同一个 Cache 里，key 加上了 "#stale" 后缀，过期时间是 staleExpiration，
+// 所以 staleExpiration 应该比正常的过期时间更长
+func WithServeStale(staleExpiration time.Duration) ReadThroughCacheOption {
+	return func(r *ReadThroughCache) {
+		r.policy = loadErrorServeStale
+		r.staleExpiration = staleExpiration
+	}
+}
+
+// WithCacheNotFound LoadFunc 返回 errs.ErrKeyNotFound 之后，
+// 在 expiration 内直接返回 errs.ErrKeyNotFound，不会再调用 LoadFunc。
+// expiration 一般要比正常的过期时间短很多，数据源里新增的数据才能尽快被读到
+func WithCacheNotFound(expiration time.Duration) ReadThroughCacheOption {
+	return func(r *ReadThroughCache) {
+		r.notFoundExpiration = expiration
+	}
+}
+
+// WithSingleflight 合并同一个 key 的并发加载，避免热点 key 过期的时候击穿到数据源。
+// 加载使用的 context 不会因为某一个调用方取消而被取消，
+// 所以 LoadFunc 需要自己控制超时
+func WithSingleflight() ReadThroughCacheOption {
+	return func(r *ReadThroughCache) {
+		r.group = &singleflight.Group{}
+	}
+}
+
+func (r *ReadThroughCache) Get(ctx context.Context, key string) (any, error) {
+	val, err := r.Cache.Get(ctx, key)
+	if err == nil {
+		if entry, ok := parseNegativeEntry(val); ok {
+			return nil, entry.err()
+		}
+		return val, nil
+	}
+	if err != errs.ErrKeyNotFound {
+		return nil, err
+	}
+	if r.group != nil {
+		return r.group.Do(ctx, key, func() (any, error) {
+			return r.load(detachedContext{Context: ctx}, key)
+		})
+	}
+	return r.load(ctx, key)
+}
+
+func (r *ReadThroughCache) Delete(ctx context.Context, key string) error {
+	if err := r.Cache.Delete(ctx, key); err != nil {
+		return err
+	}
+	if r.policy == loadErrorServeStale {
+		return r.Cache.Delete(ctx, key+staleKeySuffix)
+	}
+	return nil
+}
+
+func (r *ReadThroughCache) load(ctx context.Context, key string) (any, error) {
+	val, err := r.loadFunc(ctx, key)
+	if err != nil {
+		return r.handleLoadError(ctx, key, err)
+	}
+	if er := r.Cache.Set(ctx, key, val, r.expiration); er != nil {
+		log.Printf("cache: 刷新缓存失败, key %s, err: %v", key, er)
+	}
+	if r.policy == loadErrorServeStale {
+		if er := r.Cache.Set(ctx, key+staleKeySuffix, val, r.staleExpiration); er != nil {
+			log.Printf("cache: 保存旧值失败, key %s, err: %v", key, er)
+		}
+	}
+	return val, nil
+}
+
+func (r *ReadThroughCache) handleLoadError(ctx context.Context, key string, err error) (any, error) {
+	// 数据源明确告知不存在，不需要走失败的处理逻辑
+	if err == errs.ErrKeyNotFound {
+		if r.notFoundExpiration > 0 {
+			if er := r.Cache.Set(ctx, key, negativeEntry{notFound: true}, r.notFoundExpiration); er != nil {
+				log.Printf("cache: 缓存不存在的结果失败, key %s, err: %v", key, er)
+			}
+		}
+		return nil, err
+	}
+	switch r.policy {
+	case loadErrorCacheNegative:
+		if er := r.Cache.Set(ctx, key, negativeEntry{}, r.negativeExpiration); er != nil {
+			log.Printf("cache: 缓存失败结果失败, key %s, err: %v", key, er)
+		}
+	case loadErrorServeStale:
+		val, er := r.Cache.Get(ctx, key+staleKeySuffix)
+		if er == nil {
+			return val, nil
+		}
+	}
+	return nil, err
+}
+
+// detachedContext 保留 ctx 里面的值，但是不会被取消，也没有超时
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
@@ -0,0 +1,246 @@
+package toycache
+
+import (
+	"context"
+	"errors"
+	"github.com/aristletl/toycache/internal/errs"
+	"github.com/aristletl/toycache/mocks"
+	"github.com/go-redis/redis/v9"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadThroughCache_Get(t *testing.T) {
+	errDB := errors.New("db down")
+	testCase := []struct {
+		name string
+		// before 准备缓存的数据
+		before   func(c Cache)
+		loadFunc func(cnt *int) LoadFunc
+		opts     []ReadThroughCacheOption
+
+		// times 调用 Get 的次数
+		times    int
+		wantVal  any
+		wantErr  error
+		wantLoad int
+	}{
+		{
+			name: "命中缓存",
+			before: func(c Cache) {
+				_ = c.Set(context.Background(), "key", "cached", time.Minute)
+			},
+			loadFunc: func(cnt *int) LoadFunc {
+				return func(ctx context.Context, key string) (any, error) {
+					*cnt++
+					return "loaded", nil
+				}
+			},
+			times:    1,
+			wantVal:  "cached",
+			wantLoad: 0,
+		},
+		{
+			name: "未命中加载并回写",
+			loadFunc: func(cnt *int) LoadFunc {
+				return func(ctx context.Context, key string) (any, error) {
+					*cnt++
+					return "loaded", nil
+				}
+			},
+			times:    3,
+			wantVal:  "loaded",
+			wantLoad: 1,
+		},
+		{
+			name: "加载失败直接返回",
+			loadFunc: func(cnt *int) LoadFunc {
+				return func(ctx context.Context, key string) (any, error) {
+					*cnt++
+					return nil, errDB
+				}
+			},
+			times:    2,
+			wantErr:  errDB,
+			wantLoad: 2,
+		},
+		{
+			name: "缓存失败结果",
+			loadFunc: func(cnt *int) LoadFunc {
+				return func(ctx context.Context, key string) (any, error) {
+					*cnt++
+					return nil, errDB
+				}
+			},
+			opts:     []ReadThroughCacheOption{WithCacheLoadError(time.Minute)},
+			times:    3,
+			wantErr:  errs.ErrLoadFailed,
+			wantLoad: 1,
+		},
+		{
+			name: "缓存不存在的结果",
+			loadFunc: func(cnt *int) LoadFunc {
+				return func(ctx context.Context, key string) (any, error) {
+					*cnt++
+					return nil, errs.ErrKeyNotFound
+				}
+			},
+			opts:     []ReadThroughCacheOption{WithCacheNotFound(time.Second)},
+			times:    3,
+			wantErr:  errs.ErrKeyNotFound,
+			wantLoad: 1,
+		},
+		{
+			name: "不缓存不存在的结果",
+			loadFunc: func(cnt *int) LoadFunc {
+				return func(ctx context.Context, key string) (any, error) {
+					*cnt++
+					return nil, errs.ErrKeyNotFound
+				}
+			},
+			times:    3,
+			wantErr:  errs.ErrKeyNotFound,
+			wantLoad: 3,
+		},
+		{
+			name: "返回旧值",
+			before: func(c Cache) {
+				_ = c.Set(context.Background(), "key"+staleKeySuffix, "stale", time.Minute)
+			},
+			loadFunc: func(cnt *int) LoadFunc {
+				return func(ctx context.Context, key string) (any, error) {
+					*cnt++
+					return nil, errDB
+				}
+			},
+			opts:     []ReadThroughCacheOption{WithServeStale(time.Hour)},
+			times:    1,
+			wantVal:  "stale",
+			wantLoad: 1,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			local := NewLocalCache()
+			defer local.Close()
+			if tc.before != nil {
+				tc.before(local)
+			}
+			cnt := 0
+			c := NewReadThroughCache(local, tc.loadFunc(&cnt), time.Minute, tc.opts...)
+			var (
+				val any
+				err error
+			)
+			for i := 0; i < tc.times; i++ {
+				val, err = c.Get(context.Background(), "key")
+			}
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantVal, val)
+			assert.Equal(t, tc.wantLoad, cnt)
+		})
+	}
+}
+
+func TestReadThroughCache_NotFoundOnRedis(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cmd := mocks.NewMockCmdable(ctrl)
+	miss := redis.NewStringCmd(nil)
+	miss.SetErr(redis.Nil)
+	cmd.EXPECT().Get(gomock.Any(), "key").Return(miss)
+	ok := redis.NewStatusCmd(nil)
+	ok.SetVal("OK")
+	// 即使配置了 codec，标记也是原样写入的
+	cmd.EXPECT().Set(gomock.Any(), "key", negativeEntry{notFound: true}, time.Second).Return(ok)
+	hit := redis.NewStringCmd(nil)
+	hit.SetVal(string(notFoundMarker))
+	cmd.EXPECT().Get(gomock.Any(), "key").Return(hit)
+
+	cnt := 0
+	c := NewReadThroughCache(NewRedisCache(cmd, WithCodec(JSONCodec{})),
+		func(ctx context.Context, key string) (any, error) {
+			cnt++
+			return nil, errs.ErrKeyNotFound
+		}, time.Minute, WithCacheNotFound(time.Second))
+	for i := 0; i < 2; i++ {
+		_, err := c.Get(context.Background(), "key")
+		assert.Equal(t, errs.ErrKeyNotFound, err)
+	}
+	assert.Equal(t, 1, cnt)
+}
+
+func TestReadThroughCache_Singleflight(t *testing.T) {
+	local := NewLocalCache()
+	defer local.Close()
+	var cnt int32
+	started, release := make(chan struct{}), make(chan struct{})
+	c := NewReadThroughCache(local, func(ctx context.Context, key string) (any, error) {
+		atomic.AddInt32(&cnt, 1)
+		close(started)
+		<-release
+		// 发起加载的调用方取消了，加载依旧可以继续
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return "loaded", nil
+	}, time.Minute, WithSingleflight())
+
+	// 先启动可以被取消的调用方，由它发起加载
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := c.Get(ctx, "key")
+		leaderErr <- err
+	}()
+	<-started
+
+	// 其它调用方加入同一次加载，调用 ctx.Done 的时候已经在等待结果了
+	var (
+		wg      sync.WaitGroup
+		waiting int32
+	)
+	vals := make([]any, 10)
+	for i := range vals {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			val, err := c.Get(doneCounter{Context: context.Background(), cnt: &waiting}, "key")
+			assert.NoError(t, err)
+			vals[i] = val
+		}(i)
+	}
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&waiting) >= int32(len(vals))
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-leaderErr)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&cnt))
+	for _, val := range vals {
+		assert.Equal(t, "loaded", val)
+	}
+	// 加载的结果写入了缓存
+	val, err := local.Get(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "loaded", val)
+}
+
+// doneCounter 记录 Done 被调用的次数，用来判断调用方是否已经开始等待
+type doneCounter struct {
+	context.Context
+	cnt *int32
+}
+
+func (d doneCounter) Done() <-chan struct{} {
+	atomic.AddInt32(d.cnt, 1)
+	return d.Context.Done()
+}
@@ -3,26 +3,133 @@ package toycache
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/aristletl/toycache/internal/errs"
 	"github.com/go-redis/redis/v9"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+type RedisCacheOption func(r *RedisCache)
+
 type RedisCache struct {
 	client redis.Cmdable
+	// codec 为 nil 的时候，值原样交给 redis 客户端处理
+	codec Codec
+
+	// prefix 和 db 决定订阅哪些 key 的 keyspace 通知
+	prefix string
+	db     int
+
+	mutex     sync.Mutex
+	onEvicted []func(key string, val []byte)
+	// lastKnown 记录本实例最近读写过的值，key 被删除之后 Redis 里已经没有值了，
+	// 只能通过它告诉回调被淘汰的是什么。注册了回调之后才会开始记录
+	lastKnown *valueLRU
+	// watching 为 1 的时候 Get 和 Set 才需要记录值，避免没有回调的时候也去抢锁
+	watching int32
+	// fallback 表示客户端不支持 PSubscribe，只能在本实例 Delete 的时候触发回调
+	fallback bool
+	pubsub   *redis.PubSub
+	cancel   context.CancelFunc
+	done     chan struct{}
 }
 
 //go:generate mockgen -package mocks -destination=mocks/redis_cmdable.mock.go github.com/go-reids/redis/v9 Cmdable
-func NewRedisCache(client redis.Cmdable) *RedisCache {
-	return &RedisCache{
-		client: client,
+func NewRedisCache(client redis.Cmdable, opts ...RedisCacheOption) *RedisCache {
+	res := &RedisCache{
+		client:    client,
+		lastKnown: newValueLRU(1024),
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// WithCodec 设置值的编解码方式，设置之后 Get 返回的是编码后的 []byte，
+// 需要使用 Scan 解析到具体的结构体
+func WithCodec(codec Codec) RedisCacheOption {
+	return func(r *RedisCache) {
+		r.codec = codec
+	}
+}
+
+// WithKeyPrefix 只关注以 prefix 开头的 key 的淘汰通知
+func WithKeyPrefix(prefix string) RedisCacheOption {
+	return func(r *RedisCache) {
+		r.prefix = prefix
+	}
+}
+
+// WithEvictedValueCapacity 设置最多记录多少个 key 的值用于淘汰回调，默认是 1024。
+// 超过之后最久没有读写的 key 被淘汰回调拿到的是 nil，小于等于 0 的时候不记录任何值
+func WithEvictedValueCapacity(capacity int) RedisCacheOption {
+	return func(r *RedisCache) {
+		r.lastKnown = newValueLRU(capacity)
+	}
+}
+
+// WithDB 设置 key 所在的 db，订阅 keyspace 通知的时候需要用到
+func WithDB(db int) RedisCacheOption {
+	return func(r *RedisCache) {
+		r.db = db
 	}
 }
 
 func (r *RedisCache) Get(ctx context.Context, key string) (any, error) {
-	return r.client.Get(ctx, key).Result()
+	cmd := r.client.Get(ctx, key)
+	if cmd.Err() == redis.Nil {
+		return nil, errs.ErrKeyNotFound
+	}
+	if r.codec != nil {
+		data, err := cmd.Bytes()
+		if err == nil {
+			r.remember(key, data)
+		}
+		return data, err
+	}
+	val, err := cmd.Result()
+	if err == nil {
+		r.remember(key, []byte(val))
+	}
+	return val, err
+}
+
+// Scan 读取 key 对应的值，并解析到 val 中，val 必须是指针。
+// 解析失败的时候返回 errs.ErrTypeMismatch
+func (r *RedisCache) Scan(ctx context.Context, key string, val any) error {
+	cmd := r.client.Get(ctx, key)
+	if cmd.Err() == redis.Nil {
+		return errs.ErrKeyNotFound
+	}
+	data, err := cmd.Bytes()
+	if err != nil {
+		return err
+	}
+	if r.codec == nil {
+		err = cmd.Scan(val)
+	} else {
+		err = r.codec.Unmarshal(data, val)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: key %s, %v", errs.ErrTypeMismatch, key, err)
+	}
+	return nil
 }
 
 func (r *RedisCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	// 负缓存的标记不经过 codec，保证读出来的时候能够被识别
+	if _, ok := val.(negativeEntry); !ok && r.codec != nil {
+		data, err := r.codec.Marshal(val)
+		if err != nil {
+			return err
+		}
+		val = data
+	}
 	res, err := r.client.Set(ctx, key, val, expiration).Result()
 	if err != nil {
 		return err
@@ -30,16 +137,200 @@ func (r *RedisCache) Set(ctx context.Context, key string, val any, expiration ti
 	if res != "OK" {
 		return errors.New("cache: 设置失败")
 	}
+	data, ok := toBytes(val)
+	if !ok {
+		data = []byte(fmt.Sprint(val))
+	}
+	r.remember(key, data)
 	return nil
 }
 
+// Delete key 不存在的时候也不会返回错误
 func (r *RedisCache) Delete(ctx context.Context, key string) error {
-	res, err := r.client.Del(ctx, key).Result()
+	n, err := r.client.Del(ctx, key).Result()
 	if err != nil {
 		return err
 	}
-	if res != 0 {
-		return errors.New("cache: 删除失败")
+	r.mutex.Lock()
+	fallback := r.fallback
+	r.mutex.Unlock()
+	if fallback && n > 0 && strings.HasPrefix(key, r.prefix) {
+		r.notify(key)
 	}
 	return nil
 }
+
+// psubscriber 是支持模式订阅的客户端，*redis.Client 之类的实现都满足这个接口
+type psubscriber interface {
+	PSubscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// OnEvicted 通过订阅 keyspace 通知实现，key 过期、被 Redis 淘汰或者被删除的时候触发回调，
+// 回调拿到的值是本实例最近读写过的值，没有记录的 key 拿到的是 nil，参考 WithEvictedValueCapacity。
+// Redis 需要开启对应的通知，例如 notify-keyspace-events 设置为 Kgxe。
+// 客户端不支持 PSubscribe 的时候退化成只在本实例 Delete 成功删除 key 的时候触发回调，
+// 过期和被 Redis 淘汰都感知不到，可以通过 Watching 判断是否订阅成功
+func (r *RedisCache) OnEvicted(fn func(key string, val []byte)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.onEvicted = append(r.onEvicted, fn)
+	if r.pubsub != nil || r.fallback {
+		return
+	}
+	atomic.StoreInt32(&r.watching, 1)
+	client, ok := r.client.(psubscriber)
+	if !ok {
+		log.Println("cache: redis 客户端不支持 PSubscribe，只能感知本实例的 Delete")
+		r.fallback = true
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	r.pubsub = client.PSubscribe(ctx, r.keyspaceChannel()+escapePattern(r.prefix)+"*")
+	go r.loop(ctx, r.pubsub, r.done)
+}
+
+// Watching 返回是否订阅了 keyspace 通知，客户端不支持 PSubscribe 或者没有注册回调的时候返回 false
+func (r *RedisCache) Watching() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.pubsub != nil
+}
+
+// Close 停止监听淘汰通知，并且清空记录的值
+func (r *RedisCache) Close() error {
+	r.mutex.Lock()
+	pubsub, done := r.pubsub, r.done
+	if r.cancel != nil {
+		r.cancel()
+	}
+	atomic.StoreInt32(&r.watching, 0)
+	r.mutex.Unlock()
+	r.lastKnown.clear()
+	if pubsub == nil {
+		return nil
+	}
+	err := pubsub.Close()
+	<-done
+	return err
+}
+
+func (r *RedisCache) keyspaceChannel() string {
+	return fmt.Sprintf("__keyspace@%d__:", r.db)
+}
+
+func (r *RedisCache) loop(ctx context.Context, pubsub *redis.PubSub, done chan struct{}) {
+	defer close(done)
+	channel := r.keyspaceChannel()
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("cache: 接收淘汰通知失败, err: %v", err)
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		m, ok := msg.(*redis.Message)
+		if !ok {
+			continue
+		}
+		switch m.Payload {
+		case "expired", "evicted", "del":
+			r.notify(strings.TrimPrefix(m.Channel, channel))
+		}
+	}
+}
+
+func (r *RedisCache) remember(key string, val []byte) {
+	if atomic.LoadInt32(&r.watching) == 0 || !strings.HasPrefix(key, r.prefix) {
+		return
+	}
+	r.lastKnown.put(key, val)
+}
+
+func (r *RedisCache) notify(key string) {
+	val := r.lastKnown.take(key)
+	r.mutex.Lock()
+	fns := r.onEvicted
+	r.mutex.Unlock()
+	for _, fn := range fns {
+		fn(key, val)
+	}
+}
+
+// escapePattern 转义 PSUBSCRIBE 模式中的特殊字符
+func escapePattern(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// valueLRU 是按照条目数量限制大小的 LRU，capacity 小于等于 0 的时候什么都不记录
+type valueLRU struct {
+	mutex    sync.Mutex
+	capacity int
+	list     *linkedList
+	nodes    map[string]*node
+}
+
+func newValueLRU(capacity int) *valueLRU {
+	return &valueLRU{
+		capacity: capacity,
+		list:     newLinkedList(),
+		nodes:    make(map[string]*node),
+	}
+}
+
+func (v *valueLRU) put(key string, val []byte) {
+	if v.capacity <= 0 {
+		return
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if n, ok := v.nodes[key]; ok {
+		n.value = val
+		v.list.moveToFront(n)
+		return
+	}
+	n := &node{key: key, value: val}
+	v.nodes[key] = n
+	v.list.pushFront(n)
+	if v.list.len() > v.capacity {
+		last := v.list.back()
+		v.list.remove(last)
+		delete(v.nodes, last.key.(string))
+	}
+}
+
+// take 取出 key 的值并且删除记录，没有记录的时候返回 nil
+func (v *valueLRU) take(key string) []byte {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	n, ok := v.nodes[key]
+	if !ok {
+		return nil
+	}
+	v.list.remove(n)
+	delete(v.nodes, key)
+	return n.value.([]byte)
+}
+
+func (v *valueLRU) clear() {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.list = newLinkedList()
+	v.nodes = make(map[string]*node)
+}
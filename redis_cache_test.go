@@ -2,10 +2,13 @@ package toycache
 
 import (
 	"context"
+	"github.com/aristletl/toycache/internal/errs"
 	"github.com/aristletl/toycache/mocks"
 	"github.com/go-redis/redis/v9"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
 	"testing"
 	"time"
 )
@@ -15,6 +18,7 @@ func TestRedisCache_Set(t *testing.T) {
 	testCase := []struct {
 		name       string
 		cmd        redis.Cmdable
+		opts       []RedisCacheOption
 		key        string
 		val        any
 		expiration time.Duration
@@ -34,13 +38,192 @@ func TestRedisCache_Set(t *testing.T) {
 			val:        "value",
 			expiration: time.Minute,
 		},
+		{
+			name: "json codec",
+			cmd: func() redis.Cmdable {
+				res := mocks.NewMockCmdable(ctrl)
+				cmd := redis.NewStatusCmd(nil)
+				cmd.SetVal("OK")
+				res.EXPECT().Set(gomock.Any(), "key", []byte(`{"Name":"Tom"}`), time.Minute).
+					Return(cmd)
+				return res
+			}(),
+			opts:       []RedisCacheOption{WithCodec(JSONCodec{})},
+			key:        "key",
+			val:        testUser{Name: "Tom"},
+			expiration: time.Minute,
+		},
 	}
 
 	for _, tc := range testCase {
 		t.Run(tc.name, func(t *testing.T) {
-			client := NewRedisCache(tc.cmd)
+			client := NewRedisCache(tc.cmd, tc.opts...)
 			err := client.Set(context.Background(), tc.key, tc.val, tc.expiration)
 			assert.Equal(t, tc.wantErr, err)
 		})
 	}
 }
+
+func TestRedisCache_Scan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gobData, err := GobCodec{}.Marshal(testUser{Name: "Tom"})
+	assert.NoError(t, err)
+	testCase := []struct {
+		name    string
+		cmd     redis.Cmdable
+		opts    []RedisCacheOption
+		key     string
+		wantVal testUser
+		wantErr error
+	}{
+		{
+			name: "json codec",
+			cmd: func() redis.Cmdable {
+				res := mocks.NewMockCmdable(ctrl)
+				cmd := redis.NewStringCmd(nil)
+				cmd.SetVal(`{"Name":"Tom"}`)
+				res.EXPECT().Get(gomock.Any(), "key").Return(cmd)
+				return res
+			}(),
+			opts:    []RedisCacheOption{WithCodec(JSONCodec{})},
+			key:     "key",
+			wantVal: testUser{Name: "Tom"},
+		},
+		{
+			name: "gob codec",
+			cmd: func() redis.Cmdable {
+				res := mocks.NewMockCmdable(ctrl)
+				cmd := redis.NewStringCmd(nil)
+				cmd.SetVal(string(gobData))
+				res.EXPECT().Get(gomock.Any(), "key").Return(cmd)
+				return res
+			}(),
+			opts:    []RedisCacheOption{WithCodec(GobCodec{})},
+			key:     "key",
+			wantVal: testUser{Name: "Tom"},
+		},
+		{
+			name: "key not found",
+			cmd: func() redis.Cmdable {
+				res := mocks.NewMockCmdable(ctrl)
+				cmd := redis.NewStringCmd(nil)
+				cmd.SetErr(redis.Nil)
+				res.EXPECT().Get(gomock.Any(), "key").Return(cmd)
+				return res
+			}(),
+			opts:    []RedisCacheOption{WithCodec(JSONCodec{})},
+			key:     "key",
+			wantErr: errs.ErrKeyNotFound,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			client := NewRedisCache(tc.cmd, tc.opts...)
+			var val testUser
+			err := client.Scan(context.Background(), tc.key, &val)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantVal, val)
+		})
+	}
+}
+
+func TestRedisCache_OnEvicted(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeRedisServer(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.addr()})
+	defer client.Close()
+
+	c := NewRedisCache(client, WithKeyPrefix("user:"))
+	defer c.Close()
+	var (
+		mutex   sync.Mutex
+		evicted []string
+	)
+	c.OnEvicted(func(key string, val []byte) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		evicted = append(evicted, key+"="+string(val))
+	})
+	require.Eventually(t, func() bool {
+		return srv.psubscribers() == 1
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, c.Set(ctx, "user:1", "Tom", time.Minute))
+	require.NoError(t, c.Set(ctx, "user:2", "Jerry", time.Minute))
+	require.NoError(t, c.Set(ctx, "order:1", "book", time.Minute))
+	require.NoError(t, c.Delete(ctx, "order:1"))
+	require.NoError(t, c.Delete(ctx, "user:1"))
+	srv.expire("user:2")
+
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(evicted) == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"user:1=Tom", "user:2=Jerry"}, evicted)
+}
+
+func TestRedisCache_EvictedValueCapacity(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeRedisServer(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.addr()})
+	defer client.Close()
+
+	c := NewRedisCache(client, WithEvictedValueCapacity(1))
+	var (
+		mutex   sync.Mutex
+		evicted []string
+	)
+	c.OnEvicted(func(key string, val []byte) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		evicted = append(evicted, key+"="+string(val))
+	})
+	require.True(t, c.Watching())
+
+	require.NoError(t, c.Set(ctx, "user:1", "Tom", time.Minute))
+	require.NoError(t, c.Set(ctx, "user:2", "Jerry", time.Minute))
+	require.NoError(t, c.Delete(ctx, "user:1"))
+	require.NoError(t, c.Delete(ctx, "user:2"))
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(evicted) == 2
+	}, time.Second, time.Millisecond)
+	// 只记录了最近写入的 user:2
+	assert.Equal(t, []string{"user:1=", "user:2=Jerry"}, evicted)
+
+	require.NoError(t, c.Set(ctx, "user:3", "Spike", time.Minute))
+	require.NoError(t, c.Close())
+	assert.Nil(t, c.lastKnown.take("user:3"))
+}
+
+func TestRedisCache_OnEvictedWithoutPSubscribe(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	ctx := context.Background()
+	cmd := mocks.NewMockCmdable(ctrl)
+	setCmd := redis.NewStatusCmd(ctx)
+	setCmd.SetVal("OK")
+	cmd.EXPECT().Set(ctx, "user:1", "Tom", time.Minute).Return(setCmd)
+	cmd.EXPECT().Del(ctx, "user:1").Return(redis.NewIntResult(1, nil))
+	cmd.EXPECT().Del(ctx, "user:2").Return(redis.NewIntResult(0, nil))
+
+	c := NewRedisCache(cmd)
+	var evicted []string
+	c.OnEvicted(func(key string, val []byte) {
+		evicted = append(evicted, key+"="+string(val))
+	})
+	assert.False(t, c.Watching())
+
+	require.NoError(t, c.Set(ctx, "user:1", "Tom", time.Minute))
+	require.NoError(t, c.Delete(ctx, "user:1"))
+	// 不存在的 key 不会触发回调
+	require.NoError(t, c.Delete(ctx, "user:2"))
+	assert.Equal(t, []string{"user:1=Tom"}, evicted)
+	require.NoError(t, c.Close())
+}
+
+type testUser struct {
+	Name string
+}
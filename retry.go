@@ -18,3 +18,11 @@ func (f *fixIntervalRetry) Next() (time.Duration, bool) {
 	f.cnt++
 	return f.expiration, f.cnt < f.max
 }
+
+// NewFixIntervalRetry 每次间隔 interval 重试，加上第一次总共最多尝试 max 次
+func NewFixIntervalRetry(interval time.Duration, max int) RetryStrategy {
+	return &fixIntervalRetry{
+		expiration: interval,
+		max:        max,
+	}
+}
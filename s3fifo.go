@@ -0,0 +1,145 @@
+package toycache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// s3FIFOMaxFreq 是 S3FIFO 记录的最大访问次数
+const s3FIFOMaxFreq = 3
+
+// S3FIFO 是 S3-FIFO 淘汰策略，由三个先进先出队列组成：
+// 新的 key 进入小队列，在小队列期间被访问过的 key 淘汰的时候转移到主队列，
+// 没有被访问过的 key 直接淘汰并且记录到幽灵队列，幽灵队列里的 key 再次写入的时候直接进入主队列。
+// 主队列里的 key 淘汰的时候如果被访问过，访问次数减一之后重新排到队尾。
+// 和 Clock 一样，访问已有的 key 只需要读锁和一次原子操作
+type S3FIFO struct {
+	sync.RWMutex
+	smallCap int
+	ghostCap int
+
+	small *linkedList
+	main  *linkedList
+	cache map[any]*atomicEntry
+
+	ghost      *linkedList
+	ghostCache map[any]*node
+}
+
+// NewS3FIFO capacity 是预期的 key 的数量，小队列占 10%，幽灵队列最多记录 90% 的 key
+func NewS3FIFO(capacity int) *S3FIFO {
+	smallCap := capacity / 10
+	if smallCap < 1 {
+		smallCap = 1
+	}
+	ghostCap := capacity - smallCap
+	if ghostCap < 1 {
+		ghostCap = 1
+	}
+	return &S3FIFO{
+		smallCap:   smallCap,
+		ghostCap:   ghostCap,
+		small:      newLinkedList(),
+		main:       newLinkedList(),
+		cache:      make(map[any]*atomicEntry),
+		ghost:      newLinkedList(),
+		ghostCache: make(map[any]*node),
+	}
+}
+
+func (s *S3FIFO) Get(key any) (AnyValue, bool) {
+	s.RLock()
+	e, ok := s.cache[key]
+	s.RUnlock()
+	if !ok {
+		return AnyValue{}, false
+	}
+	return AnyValue{Val: e.load()}, true
+}
+
+// GetEliminatedKey 小队列超过目标大小的时候从小队列淘汰，否则从主队列淘汰。
+// 寻找被淘汰的 key 的过程中会调整 key 所在的队列以及访问次数
+func (s *S3FIFO) GetEliminatedKey() (any, AnyValue, bool) {
+	s.Lock()
+	defer s.Unlock()
+	if s.small.len() >= s.smallCap || s.main.len() == 0 {
+		for n := s.small.back(); n != nil; n = s.small.back() {
+			e := s.cache[n.key]
+			if atomic.SwapInt32(&e.freq, 0) == 0 {
+				return n.key, AnyValue{Val: e.load()}, true
+			}
+			s.small.remove(n)
+			s.main.pushFront(n)
+			e.small = false
+		}
+	}
+	for n := s.main.back(); n != nil; n = s.main.back() {
+		e := s.cache[n.key]
+		freq := atomic.LoadInt32(&e.freq)
+		if freq == 0 {
+			return n.key, AnyValue{Val: e.load()}, true
+		}
+		atomic.CompareAndSwapInt32(&e.freq, freq, freq-1)
+		s.main.moveToFront(n)
+	}
+	return nil, AnyValue{}, false
+}
+
+func (s *S3FIFO) Add(key any, args ...any) {
+	if key == nil {
+		return
+	}
+	var val any
+	if len(args) != 0 {
+		val = args[0]
+	}
+
+	s.RLock()
+	e, ok := s.cache[key]
+	s.RUnlock()
+	if ok {
+		e.touch(val, s3FIFOMaxFreq)
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if e, ok = s.cache[key]; ok {
+		e.touch(val, s3FIFOMaxFreq)
+		return
+	}
+	e = newAtomicEntry(key, val)
+	s.cache[key] = e
+	if n, ok := s.ghostCache[key]; ok {
+		delete(s.ghostCache, key)
+		s.ghost.remove(n)
+		s.main.pushFront(e.node)
+		return
+	}
+	e.small = true
+	s.small.pushFront(e.node)
+}
+
+// Remove 从小队列移除的 key 会记录到幽灵队列
+func (s *S3FIFO) Remove(key any) bool {
+	s.Lock()
+	defer s.Unlock()
+	e, ok := s.cache[key]
+	if !ok {
+		return false
+	}
+	delete(s.cache, key)
+	if !e.small {
+		s.main.remove(e.node)
+		return true
+	}
+	s.small.remove(e.node)
+	s.ghost.pushFront(e.node)
+	s.ghostCache[key] = e.node
+	if s.ghost.len() > s.ghostCap {
+		n := s.ghost.back()
+		s.ghost.remove(n)
+		delete(s.ghostCache, n.key)
+	}
+	return true
+}
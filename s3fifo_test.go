@@ -0,0 +1,47 @@
+package toycache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestS3FIFO(t *testing.T) {
+	s := NewS3FIFO(20)
+	_, _, ok := s.GetEliminatedKey()
+	assert.False(t, ok)
+
+	for i := 1; i <= 4; i++ {
+		s.Add(i, i)
+	}
+	// 在小队列期间被访问过的 key 转移到主队列，其它的 key 被淘汰并且记录到幽灵队列
+	s.Add(1, 1)
+	assert.Equal(t, []any{2, 3}, evictN(t, s, 2))
+	assert.False(t, s.cache[1].small)
+	assert.Equal(t, 2, s.ghost.len())
+
+	// 幽灵队列里的 key 直接进入主队列
+	s.Add(2, 2)
+	assert.False(t, s.cache[2].small)
+	_, ok = s.ghostCache[2]
+	assert.False(t, ok)
+
+	// 小队列没有超过目标大小，从主队列淘汰，访问过的 key 重新排到队尾
+	s.Add(1, 1)
+	assert.Equal(t, []any{2}, evictN(t, s, 1))
+	assert.Equal(t, 1, s.ghost.len())
+	assert.Equal(t, []any{1, 4}, evictN(t, s, 2))
+	assert.False(t, s.Remove(1))
+}
+
+func TestS3FIFO_Ghost(t *testing.T) {
+	s := NewS3FIFO(4)
+	for i := 0; i < 10; i++ {
+		s.Add(i, i)
+		evictN(t, s, 1)
+	}
+	// 幽灵队列最多记录 3 个 key
+	assert.Equal(t, 3, s.ghost.len())
+	assert.Equal(t, 3, len(s.ghostCache))
+	_, ok := s.ghostCache[9]
+	assert.True(t, ok)
+}
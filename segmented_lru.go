@@ -0,0 +1,123 @@
+package toycache
+
+import "sync"
+
+type SegmentedLRUOption func(s *SegmentedLRU)
+
+// SegmentedLRU 是分段 LRU 淘汰策略。新的 key 先进入试用段，
+// 在试用段里再次被访问的 key 晋升到保护段，保护段满了之后最久没有访问的 key 降级回试用段。
+// 淘汰的时候优先淘汰试用段，只访问一次的 key 不会挤掉保护段里的 key
+type SegmentedLRU struct {
+	sync.Mutex
+	protectedCap int
+	ratio        float64
+
+	probation *linkedList
+	protected *linkedList
+	cache     map[any]*segmentedLRUEntry
+}
+
+type segmentedLRUEntry struct {
+	node      *node
+	protected bool
+}
+
+// NewSegmentedLRU capacity 是预期的 key 的数量，默认保护段占 80%
+func NewSegmentedLRU(capacity int, opts ...SegmentedLRUOption) *SegmentedLRU {
+	res := &SegmentedLRU{
+		ratio:     0.8,
+		probation: newLinkedList(),
+		protected: newLinkedList(),
+		cache:     make(map[any]*segmentedLRUEntry),
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	res.protectedCap = int(float64(capacity) * res.ratio)
+	if res.protectedCap < 1 {
+		res.protectedCap = 1
+	}
+	return res
+}
+
+// WithProtectedRatio 设置保护段占总容量的比例，取值范围是 (0, 1)
+func WithProtectedRatio(ratio float64) SegmentedLRUOption {
+	return func(s *SegmentedLRU) {
+		s.ratio = ratio
+	}
+}
+
+func (s *SegmentedLRU) Get(key any) (AnyValue, bool) {
+	s.Lock()
+	defer s.Unlock()
+	e, ok := s.cache[key]
+	if !ok {
+		return AnyValue{}, false
+	}
+	return AnyValue{Val: e.node.value}, true
+}
+
+// GetEliminatedKey 优先淘汰试用段最久没有访问的 key，试用段为空的时候才淘汰保护段
+func (s *SegmentedLRU) GetEliminatedKey() (any, AnyValue, bool) {
+	s.Lock()
+	defer s.Unlock()
+	n := s.probation.back()
+	if n == nil {
+		n = s.protected.back()
+	}
+	if n == nil {
+		return nil, AnyValue{}, false
+	}
+	return n.key, AnyValue{Val: n.value}, true
+}
+
+func (s *SegmentedLRU) Add(key any, args ...any) {
+	if key == nil {
+		return
+	}
+	var val any
+	if len(args) != 0 {
+		val = args[0]
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	e, ok := s.cache[key]
+	if !ok {
+		e = &segmentedLRUEntry{node: &node{key: key, value: val}}
+		s.cache[key] = e
+		s.probation.pushFront(e.node)
+		return
+	}
+
+	e.node.value = val
+	if e.protected {
+		s.protected.moveToFront(e.node)
+		return
+	}
+	s.probation.remove(e.node)
+	s.protected.pushFront(e.node)
+	e.protected = true
+	if s.protected.len() > s.protectedCap {
+		n := s.protected.back()
+		s.protected.remove(n)
+		s.probation.pushFront(n)
+		s.cache[n.key].protected = false
+	}
+}
+
+func (s *SegmentedLRU) Remove(key any) bool {
+	s.Lock()
+	defer s.Unlock()
+	e, ok := s.cache[key]
+	if !ok {
+		return false
+	}
+	delete(s.cache, key)
+	if e.protected {
+		s.protected.remove(e.node)
+	} else {
+		s.probation.remove(e.node)
+	}
+	return true
+}
@@ -0,0 +1,27 @@
+package toycache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSegmentedLRU(t *testing.T) {
+	s := NewSegmentedLRU(4, WithProtectedRatio(0.5))
+	_, _, ok := s.GetEliminatedKey()
+	assert.False(t, ok)
+
+	for i := 1; i <= 4; i++ {
+		s.Add(i, i)
+	}
+	// 1、2、3 先后晋升，保护段只能放 2 个，1 被降级回试用段
+	s.Add(1, 1)
+	s.Add(2, 2)
+	s.Add(3, 3)
+	assert.False(t, s.cache[1].protected)
+	assert.True(t, s.cache[3].protected)
+
+	assert.Equal(t, []any{4, 1, 2, 3}, evictN(t, s, 4))
+	_, _, ok = s.GetEliminatedKey()
+	assert.False(t, ok)
+	assert.False(t, s.Remove(1))
+}
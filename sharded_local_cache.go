@@ -0,0 +1,91 @@
+package toycache
+
+import (
+	"context"
+	"time"
+)
+
+// ShardedLocalCache 把数据按照 key 的哈希值分散到多个 LocalCache 上，
+// 每个分片有自己的锁和过期清理的 goroutine，减少全局锁的竞争
+type ShardedLocalCache struct {
+	shards []*LocalCache
+	mask   uint64
+}
+
+// NewShardedLocalCache shards 会被向上取整为 2 的幂，opts 会作用于每一个分片
+func NewShardedLocalCache(shards int, opts ...LocalCacheOption) *ShardedLocalCache {
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	res := &ShardedLocalCache{
+		shards: make([]*LocalCache, n),
+		mask:   uint64(n - 1),
+	}
+	for i := range res.shards {
+		res.shards[i] = NewLocalCache(opts...)
+	}
+	return res
+}
+
+func (s *ShardedLocalCache) shard(key string) *LocalCache {
+	return s.shards[fnv64a(key)&s.mask]
+}
+
+func (s *ShardedLocalCache) Get(ctx context.Context, key string) (any, error) {
+	return s.shard(key).Get(ctx, key)
+}
+
+func (s *ShardedLocalCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	return s.shard(key).Set(ctx, key, val, expiration)
+}
+
+func (s *ShardedLocalCache) Delete(ctx context.Context, key string) error {
+	return s.shard(key).Delete(ctx, key)
+}
+
+func (s *ShardedLocalCache) OnEvicted(fn func(key string, val []byte)) {
+	for _, shard := range s.shards {
+		shard.OnEvicted(fn)
+	}
+}
+
+func (s *ShardedLocalCache) OnEvictedWithReason(fn func(key string, val any, reason EvictReason)) {
+	for _, shard := range s.shards {
+		shard.OnEvictedWithReason(fn)
+	}
+}
+
+func (s *ShardedLocalCache) Evict(ctx context.Context, key string) error {
+	return s.shard(key).Evict(ctx, key)
+}
+
+func (s *ShardedLocalCache) Clear(ctx context.Context) error {
+	for _, shard := range s.shards {
+		if err := shard.Clear(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ShardedLocalCache) Close() error {
+	for _, shard := range s.shards {
+		_ = shard.Close()
+	}
+	return nil
+}
+
+// fnv64a 是不需要分配内存的 FNV-1a 哈希
+func fnv64a(key string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= prime64
+	}
+	return h
+}
@@ -0,0 +1,68 @@
+package toycache
+
+import (
+	"context"
+	"github.com/aristletl/toycache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShardedLocalCache(t *testing.T) {
+	ctx := context.Background()
+	c := NewShardedLocalCache(5)
+	defer c.Close()
+	assert.Equal(t, 8, len(c.shards))
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, c.Set(ctx, strconv.Itoa(i), i, time.Minute))
+	}
+	for i := 0; i < 100; i++ {
+		val, err := c.Get(ctx, strconv.Itoa(i))
+		assert.NoError(t, err)
+		assert.Equal(t, i, val)
+	}
+	assert.NoError(t, c.Delete(ctx, "1"))
+	_, err := c.Get(ctx, "1")
+	assert.Equal(t, errs.ErrKeyNotFound, err)
+}
+
+const benchKeys = 1 << 14
+
+func benchmarkCache(b *testing.B, c Cache) {
+	ctx := context.Background()
+	keys := make([]string, benchKeys)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+		_ = c.Set(ctx, keys[i], i, time.Hour)
+	}
+	var seq uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(atomic.AddUint64(&seq, 1) * 7919)
+		for pb.Next() {
+			key := keys[i&(benchKeys-1)]
+			// 读写比例 4:1
+			if i%5 == 0 {
+				_ = c.Set(ctx, key, i, time.Hour)
+			} else {
+				_, _ = c.Get(ctx, key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkLocalCache(b *testing.B) {
+	c := NewLocalCache()
+	defer c.Close()
+	benchmarkCache(b, c)
+}
+
+func BenchmarkShardedLocalCache(b *testing.B) {
+	c := NewShardedLocalCache(32)
+	defer c.Close()
+	benchmarkCache(b, c)
+}
@@ -0,0 +1,292 @@
+package toycache
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	tinyLFUWindow = iota + 1
+	tinyLFUProbation
+	tinyLFUProtected
+)
+
+// TinyLFU 是 Caffeine 风格的 W-TinyLFU 淘汰策略。
+// 新的 key 先进入一个很小的窗口 LRU，窗口满了之后进入主区的试用段，
+// 试用段里再次被访问的 key 晋升到保护段，主区是一个分段 LRU。
+// 选择淘汰的 key 的时候，用 count-min sketch 估算窗口里最老的 key 和试用段里最老的 key 的访问频率，
+// 淘汰频率低的那一个。同时它实现了 Admitter，容量不足的时候可以直接拒绝低频的新 key
+type TinyLFU struct {
+	sync.Mutex
+	windowCap    int
+	mainCap      int
+	protectedCap int
+
+	window    *linkedList
+	probation *linkedList
+	protected *linkedList
+	cache     map[any]*tinyLFUEntry
+	sketch    *countMinSketch
+	// admitted 是最近一次允许写入的 key，Admit 已经记录过这次访问，
+	// 紧接着的 Add 不需要再增加它的频率
+	admitted any
+}
+
+type tinyLFUEntry struct {
+	node    *node
+	segment int
+}
+
+// NewTinyLFU capacity 是预期的 key 的数量，用来划分各个分区以及确定 sketch 的大小。
+// 窗口占 1%，主区的保护段占主区的 80%
+func NewTinyLFU(capacity int) *TinyLFU {
+	if capacity < 1 {
+		capacity = 1
+	}
+	windowCap := capacity / 100
+	if windowCap < 1 {
+		windowCap = 1
+	}
+	mainCap := capacity - windowCap
+	return &TinyLFU{
+		windowCap:    windowCap,
+		mainCap:      mainCap,
+		protectedCap: mainCap * 8 / 10,
+		window:       newLinkedList(),
+		probation:    newLinkedList(),
+		protected:    newLinkedList(),
+		cache:        make(map[any]*tinyLFUEntry),
+		sketch:       newCountMinSketch(capacity),
+	}
+}
+
+func (t *TinyLFU) Get(key any) (AnyValue, bool) {
+	t.Lock()
+	defer t.Unlock()
+	e, ok := t.cache[key]
+	if !ok {
+		return AnyValue{}, false
+	}
+	return AnyValue{Val: e.node.value}, true
+}
+
+// Admit 新的 key 的访问频率比将要被淘汰的 key 高的时候才允许写入。
+// 被拒绝的写入也算一次访问，这样反复写入的 key 最终能够进入缓存
+func (t *TinyLFU) Admit(key any) bool {
+	t.Lock()
+	defer t.Unlock()
+	t.sketch.increment(key)
+	if _, ok := t.cache[key]; !ok {
+		victim := t.victim()
+		if victim != nil && t.sketch.estimate(key) <= t.sketch.estimate(victim.key) {
+			return false
+		}
+	}
+	t.admitted = key
+	return true
+}
+
+func (t *TinyLFU) GetEliminatedKey() (any, AnyValue, bool) {
+	t.Lock()
+	defer t.Unlock()
+	n := t.victim()
+	if n == nil {
+		return nil, AnyValue{}, false
+	}
+	return n.key, AnyValue{Val: n.value}, true
+}
+
+// victim 窗口超出容量的时候，窗口里最老的 key 要和试用段里最老的 key 竞争，
+// 否则优先淘汰试用段，再是保护段，最后才是窗口
+func (t *TinyLFU) victim() *node {
+	candidate, victim := t.window.back(), t.probation.back()
+	if candidate != nil && (t.window.len() > t.windowCap || victim == nil) {
+		if victim != nil && t.sketch.estimate(candidate.key) > t.sketch.estimate(victim.key) {
+			return victim
+		}
+		if victim == nil && t.protected.len() > 0 && t.window.len() <= t.windowCap {
+			return t.protected.back()
+		}
+		return candidate
+	}
+	if victim != nil {
+		return victim
+	}
+	if n := t.protected.back(); n != nil {
+		return n
+	}
+	return candidate
+}
+
+func (t *TinyLFU) Add(key any, args ...any) {
+	if key == nil {
+		return
+	}
+	var val any
+	if len(args) != 0 {
+		val = args[0]
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	if t.admitted != nil && t.admitted == key {
+		t.admitted = nil
+	} else {
+		t.sketch.increment(key)
+	}
+	e, ok := t.cache[key]
+	if !ok {
+		e = &tinyLFUEntry{node: &node{key: key, value: val}, segment: tinyLFUWindow}
+		t.cache[key] = e
+		t.window.pushFront(e.node)
+		t.balance()
+		return
+	}
+
+	e.node.value = val
+	switch e.segment {
+	case tinyLFUWindow:
+		t.window.moveToFront(e.node)
+	case tinyLFUProbation:
+		t.probation.remove(e.node)
+		t.protected.pushFront(e.node)
+		e.segment = tinyLFUProtected
+		if t.protected.len() > t.protectedCap {
+			n := t.protected.back()
+			t.protected.remove(n)
+			t.probation.pushFront(n)
+			t.cache[n.key].segment = tinyLFUProbation
+		}
+	case tinyLFUProtected:
+		t.protected.moveToFront(e.node)
+	}
+}
+
+func (t *TinyLFU) Remove(key any) bool {
+	t.Lock()
+	defer t.Unlock()
+	e, ok := t.cache[key]
+	if !ok {
+		return false
+	}
+	delete(t.cache, key)
+	t.segment(e.segment).remove(e.node)
+	t.balance()
+	return true
+}
+
+func (t *TinyLFU) segment(segment int) *linkedList {
+	switch segment {
+	case tinyLFUWindow:
+		return t.window
+	case tinyLFUProbation:
+		return t.probation
+	default:
+		return t.protected
+	}
+}
+
+// balance 主区还有空间的时候，把窗口里超出容量的 key 挪到试用段
+func (t *TinyLFU) balance() {
+	for t.window.len() > t.windowCap && t.probation.len()+t.protected.len() < t.mainCap {
+		n := t.window.back()
+		t.window.remove(n)
+		t.probation.pushFront(n)
+		t.cache[n.key].segment = tinyLFUProbation
+	}
+}
+
+// countMinSketch 用 4 行计数器估算 key 的访问频率，每个计数器最大为 15。
+// 记录的次数达到 sampleSize 之后所有计数器减半，让频率能够反映最近的访问情况
+type countMinSketch struct {
+	rows       [4][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+var sketchSeeds = [4]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+func newCountMinSketch(capacity int) *countMinSketch {
+	width := 16
+	for width < capacity {
+		width <<= 1
+	}
+	res := &countMinSketch{
+		mask:       uint64(width - 1),
+		sampleSize: 10 * capacity,
+	}
+	for i := range res.rows {
+		res.rows[i] = make([]uint8, width)
+	}
+	return res
+}
+
+func (c *countMinSketch) index(h uint64, row int) uint64 {
+	h = (h ^ sketchSeeds[row]) * 0x9e3779b97f4a7c15
+	return (h >> 32) & c.mask
+}
+
+func (c *countMinSketch) increment(key any) {
+	h := hashKey(key)
+	for i := range c.rows {
+		idx := c.index(h, i)
+		if c.rows[i][idx] < 15 {
+			c.rows[i][idx]++
+		}
+	}
+	c.additions++
+	if c.additions >= c.sampleSize {
+		c.reset()
+	}
+}
+
+func (c *countMinSketch) estimate(key any) uint8 {
+	h := hashKey(key)
+	res := uint8(15)
+	for i := range c.rows {
+		if v := c.rows[i][c.index(h, i)]; v < res {
+			res = v
+		}
+	}
+	return res
+}
+
+func (c *countMinSketch) reset() {
+	for i := range c.rows {
+		for j := range c.rows[i] {
+			c.rows[i][j] >>= 1
+		}
+	}
+	c.additions /= 2
+}
+
+// hashKey 计算任意类型的 key 的哈希值，常见的类型不需要格式化成字符串
+func hashKey(key any) uint64 {
+	switch k := key.(type) {
+	case string:
+		return fnv64a(k)
+	case int:
+		return mix64(uint64(k))
+	case int64:
+		return mix64(uint64(k))
+	case uint64:
+		return mix64(k)
+	case int32:
+		return mix64(uint64(k))
+	case uint32:
+		return mix64(uint64(k))
+	default:
+		return fnv64a(fmt.Sprint(k))
+	}
+}
+
+// mix64 是 splitmix64 的最后一步，把相邻的整数打散
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,202 @@
+package toycache
+
+import (
+	"context"
+	"fmt"
+	"github.com/aristletl/toycache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestTinyLFU_GetEliminatedKey(t *testing.T) {
+	testCase := []struct {
+		name    string
+		cache   func() *TinyLFU
+		wantKey any
+		wantOk  bool
+	}{
+		{
+			name: "没有数据",
+			cache: func() *TinyLFU {
+				return NewTinyLFU(100)
+			},
+		},
+		{
+			name: "窗口里的低频 key 被淘汰",
+			cache: func() *TinyLFU {
+				l := NewTinyLFU(100)
+				// 1 进入主区之后被多次访问
+				l.Add(1, 1)
+				l.Add(2, 2)
+				l.Add(1, 1)
+				l.Add(1, 1)
+				l.Add(3, 3)
+				return l
+			},
+			wantKey: 2,
+			wantOk:  true,
+		},
+		{
+			name: "窗口里的高频 key 替换主区的低频 key",
+			cache: func() *TinyLFU {
+				l := NewTinyLFU(100)
+				l.Add(1, 1)
+				l.Add(2, 2)
+				l.Add(2, 2)
+				l.Add(2, 2)
+				return l
+			},
+			wantKey: 1,
+			wantOk:  true,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			key, _, ok := tc.cache().GetEliminatedKey()
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantKey, key)
+		})
+	}
+}
+
+func TestTinyLFU_Promote(t *testing.T) {
+	l := NewTinyLFU(100)
+	for i := 0; i < 10; i++ {
+		l.Add(i, i)
+	}
+	// 再次访问的 key 晋升到保护段，不会优先被淘汰
+	l.Add(1, 1)
+	for i := 0; i < 8; i++ {
+		key, _, ok := l.GetEliminatedKey()
+		require.True(t, ok)
+		assert.NotEqual(t, 1, key)
+		assert.True(t, l.Remove(key))
+	}
+	assert.False(t, l.Remove(100))
+}
+
+func TestTinyLFU_AdmitCountsOnce(t *testing.T) {
+	l := NewTinyLFU(100)
+	require.True(t, l.Admit("a"))
+	l.Add("a", 1)
+	// Admit 已经记录了这次访问，Add 不会重复计数
+	assert.Equal(t, uint8(1), l.sketch.estimate("a"))
+	l.Add("a", 1)
+	assert.Equal(t, uint8(2), l.sketch.estimate("a"))
+	// 被拒绝的 key 也记录了访问，之后的 Add 仍然需要计数
+	require.False(t, l.Admit("b"))
+	assert.Equal(t, uint8(1), l.sketch.estimate("b"))
+	l.Add("c", 1)
+	assert.Equal(t, uint8(1), l.sketch.estimate("c"))
+}
+
+func TestTinyLFU_Reset(t *testing.T) {
+	s := newCountMinSketch(10)
+	for i := 0; i < 15; i++ {
+		s.increment("hot")
+	}
+	assert.Equal(t, uint8(15), s.estimate("hot"))
+	// 记录 100 次之后所有计数器减半
+	for i := 0; i < 85; i++ {
+		s.increment(i)
+	}
+	assert.True(t, s.estimate("hot") <= 7)
+}
+
+func TestMaxMemoryCache_Admit(t *testing.T) {
+	ctx := context.Background()
+	local := NewLocalCache()
+	defer local.Close()
+	c := NewMaxMemoryCache(30, 30, NewTinyLFU(10), local)
+
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, c.Set(ctx, key, make([]byte, 10), time.Minute))
+		for i := 0; i < 3; i++ {
+			_, err := c.Get(ctx, key)
+			require.NoError(t, err)
+		}
+	}
+	// 新的 key 访问频率太低，拒绝写入
+	err := c.Set(ctx, "d", make([]byte, 10), time.Minute)
+	assert.Equal(t, errs.ErrNotAdmitted, err)
+	_, err = local.Get(ctx, "d")
+	assert.Equal(t, errs.ErrKeyNotFound, err)
+	// 覆盖写不需要准入
+	assert.NoError(t, c.Set(ctx, "a", make([]byte, 10), time.Minute))
+
+	// 多次写入之后访问频率上来了，淘汰已有的 key
+	for i := 0; i < 5; i++ {
+		err = c.Set(ctx, "d", make([]byte, 10), time.Minute)
+	}
+	assert.NoError(t, err)
+	_, err = local.Get(ctx, "d")
+	assert.NoError(t, err)
+}
+
+// simulateHitRatio 用 capacity 个 key 的缓存模拟 trace，返回命中率
+func simulateHitRatio(s EliminateStrategy, capacity int, trace []int) float64 {
+	size, hits := 0, 0
+	for _, key := range trace {
+		if _, ok := s.Get(key); ok {
+			hits++
+			s.Add(key, 1)
+			continue
+		}
+		if a, ok := s.(Admitter); ok && size >= capacity && !a.Admit(key) {
+			continue
+		}
+		for size >= capacity {
+			victim, _, ok := s.GetEliminatedKey()
+			if !ok {
+				break
+			}
+			s.Remove(victim)
+			size--
+		}
+		s.Add(key, 1)
+		size++
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+func zipfTrace(n int, keys uint64, skew float64) []int {
+	z := rand.NewZipf(rand.New(rand.NewSource(1)), skew, 1, keys-1)
+	res := make([]int, n)
+	for i := range res {
+		res[i] = int(z.Uint64())
+	}
+	return res
+}
+
+func BenchmarkHitRatio(b *testing.B) {
+	const capacity = 1000
+	strategies := []struct {
+		name   string
+		newFun func() EliminateStrategy
+	}{
+		{name: "LRU", newFun: func() EliminateStrategy { return NewLRU() }},
+		{name: "LFU", newFun: func() EliminateStrategy { return NewLFU() }},
+		{name: "TinyLFU", newFun: func() EliminateStrategy { return NewTinyLFU(capacity) }},
+		{name: "ARC", newFun: func() EliminateStrategy { return NewARC(capacity) }},
+		{name: "TwoQueue", newFun: func() EliminateStrategy { return NewTwoQueue(capacity) }},
+		{name: "SegmentedLRU", newFun: func() EliminateStrategy { return NewSegmentedLRU(capacity) }},
+		{name: "Clock", newFun: func() EliminateStrategy { return NewClock() }},
+		{name: "S3FIFO", newFun: func() EliminateStrategy { return NewS3FIFO(capacity) }},
+	}
+	for _, skew := range []float64{1.01, 1.2} {
+		trace := zipfTrace(200000, 100000, skew)
+		for _, s := range strategies {
+			b.Run(fmt.Sprintf("zipf-%.2f/%s", skew, s.name), func(b *testing.B) {
+				var ratio float64
+				for i := 0; i < b.N; i++ {
+					ratio = simulateHitRatio(s.newFun(), capacity, trace)
+				}
+				b.ReportMetric(ratio*100, "hit%")
+			})
+		}
+	}
+}
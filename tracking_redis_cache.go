@@ -0,0 +1,307 @@
+package toycache
+
+import (
+	"context"
+	"github.com/aristletl/toycache/internal/errs"
+	"github.com/go-redis/redis/v9"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// trackingChannel 是 Redis 在 RESP2 重定向模式下发送失效消息的频道
+const trackingChannel = "__redis__:invalidate"
+
+var _ Cache = &TrackingRedisCache{}
+
+type TrackingRedisCacheOption func(t *TrackingRedisCache)
+
+// TrackingRedisCache 利用 Redis 6 的客户端缓存（CLIENT TRACKING），
+// 把读到的数据缓存在本地，key 被修改之后 Redis 会推送失效消息，收到之后删除本地的数据。
+//
+// go-redis 的普通连接无法处理 RESP3 的推送消息，所以这里使用的是重定向模式：
+// 单独建立一个订阅 __redis__:invalidate 的连接，
+// 其它连接都通过 CLIENT TRACKING ON REDIRECT 把失效消息转发到这个连接上
+type TrackingRedisCache struct {
+	opt             redis.Options
+	localExpiration time.Duration
+	bcast           bool
+	prefixes        []string
+
+	subscriber *redis.Client
+	pubsub     *redis.PubSub
+	// redirectID 是订阅连接的 CLIENT ID
+	redirectID int64
+
+	mutex     sync.RWMutex
+	client    *redis.Client
+	local     *LocalCache
+	onEvicted []func(key string, val []byte)
+
+	// invalidations 每收到一次失效消息就加一，
+	// 读 Redis 期间如果发生过失效，读到的值就不写入本地缓存
+	invalidations uint64
+	// fillMutex 保证 Get 检查 invalidations 和写入本地缓存是一步完成的，
+	// 处理失效消息的时候也要持有它，否则失效消息可能夹在两者中间，旧值会被写回本地
+	fillMutex sync.Mutex
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewTrackingRedisCache opt 是连接 Redis 的配置，订阅连接和读写连接都会使用它
+func NewTrackingRedisCache(opt *redis.Options, opts ...TrackingRedisCacheOption) (*TrackingRedisCache, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	res := &TrackingRedisCache{
+		opt:             *opt,
+		localExpiration: time.Minute,
+		local:           NewLocalCache(),
+		cancel:          cancel,
+		done:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+
+	subOpt := res.opt
+	subOpt.OnConnect = func(ctx context.Context, cn *redis.Conn) error {
+		if opt.OnConnect != nil {
+			if err := opt.OnConnect(ctx, cn); err != nil {
+				return err
+			}
+		}
+		id, err := cn.ClientID(ctx).Result()
+		if err != nil {
+			return err
+		}
+		atomic.StoreInt64(&res.redirectID, id)
+		return nil
+	}
+	res.subscriber = redis.NewClient(&subOpt)
+	res.pubsub = res.subscriber.Subscribe(ctx, trackingChannel)
+	// 等待订阅成功，这个时候才能拿到订阅连接的 ID
+	if _, err := res.pubsub.Receive(ctx); err != nil {
+		cancel()
+		_ = res.pubsub.Close()
+		_ = res.subscriber.Close()
+		_ = res.local.Close()
+		return nil, err
+	}
+	res.client = res.newClient()
+
+	go res.loop(ctx)
+	return res, nil
+}
+
+// WithTrackingLocalExpiration 设置本地缓存的过期时间，
+// 用于兜底连接断开之类的无法收到失效消息的场景
+func WithTrackingLocalExpiration(expiration time.Duration) TrackingRedisCacheOption {
+	return func(t *TrackingRedisCache) {
+		t.localExpiration = expiration
+	}
+}
+
+// WithTrackingBroadcast 使用广播模式，Redis 不再记录每个连接读过哪些 key，
+// 而是把所有以 prefixes 开头的 key 的失效消息都发过来。没有 prefixes 表示所有的 key
+func WithTrackingBroadcast(prefixes ...string) TrackingRedisCacheOption {
+	return func(t *TrackingRedisCache) {
+		t.bcast = true
+		t.prefixes = prefixes
+	}
+}
+
+func (t *TrackingRedisCache) Get(ctx context.Context, key string) (any, error) {
+	t.mutex.RLock()
+	client, local := t.client, t.local
+	t.mutex.RUnlock()
+
+	val, err := local.Get(ctx, key)
+	if err == nil {
+		return val, nil
+	}
+
+	before := atomic.LoadUint64(&t.invalidations)
+	val, err = client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return nil, errs.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.fillMutex.Lock()
+	if atomic.LoadUint64(&t.invalidations) == before {
+		_ = local.Set(ctx, key, val, t.localExpiration)
+	}
+	t.fillMutex.Unlock()
+	return val, nil
+}
+
+func (t *TrackingRedisCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	t.mutex.RLock()
+	client, local := t.client, t.local
+	t.mutex.RUnlock()
+	if err := client.Set(ctx, key, val, expiration).Err(); err != nil {
+		return err
+	}
+	return local.Delete(ctx, key)
+}
+
+func (t *TrackingRedisCache) Delete(ctx context.Context, key string) error {
+	t.mutex.RLock()
+	client, local := t.client, t.local
+	t.mutex.RUnlock()
+	if err := client.Del(ctx, key).Err(); err != nil {
+		return err
+	}
+	val, _ := local.Get(ctx, key)
+	if err := local.Delete(ctx, key); err != nil {
+		return err
+	}
+	t.notify(key, val)
+	return nil
+}
+
+// OnEvicted 注册淘汰的回调，回调拿到的是本地缓存里的值，没有缓存在本地的时候是 nil。
+// 以下情况会触发回调：
+//   - 本实例调用 Delete
+//   - 收到失效消息，并且本地缓存了这个 key。失效消息可能是因为 key 被删除、过期，也可能是被修改
+//   - 连接断开之类的原因导致本地缓存被清空，本地缓存里的每一个 key 都会触发
+//
+// 本地缓存自己过期以及本实例调用 Set 不会触发回调，因为 Redis 里的数据还在
+func (t *TrackingRedisCache) OnEvicted(fn func(key string, val []byte)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.onEvicted = append(t.onEvicted, fn)
+}
+
+func (t *TrackingRedisCache) notify(key string, val any) {
+	t.mutex.RLock()
+	fns := t.onEvicted
+	t.mutex.RUnlock()
+	data, _ := toBytes(val)
+	for _, fn := range fns {
+		fn(key, data)
+	}
+}
+
+func (t *TrackingRedisCache) Close() error {
+	var err error
+	t.once.Do(func() {
+		t.cancel()
+		err = t.pubsub.Close()
+		<-t.done
+		_ = t.subscriber.Close()
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		_ = t.local.Close()
+		if er := t.client.Close(); er != nil {
+			err = er
+		}
+	})
+	return err
+}
+
+// newClient 创建读写使用的客户端，每个新连接都会开启 tracking 并且重定向到订阅连接
+func (t *TrackingRedisCache) newClient() *redis.Client {
+	opt := t.opt
+	onConnect := opt.OnConnect
+	opt.OnConnect = func(ctx context.Context, cn *redis.Conn) error {
+		if onConnect != nil {
+			if err := onConnect(ctx, cn); err != nil {
+				return err
+			}
+		}
+		args := []any{"client", "tracking", "on", "redirect", atomic.LoadInt64(&t.redirectID)}
+		if t.bcast {
+			args = append(args, "bcast")
+			for _, prefix := range t.prefixes {
+				args = append(args, "prefix", prefix)
+			}
+		}
+		return cn.Process(ctx, redis.NewStatusCmd(ctx, args...))
+	}
+	return redis.NewClient(&opt)
+}
+
+func (t *TrackingRedisCache) loop(ctx context.Context) {
+	defer close(t.done)
+	for {
+		msg, err := t.pubsub.Receive(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// 可能是连接断开，也可能是 FLUSHALL 之类的空消息，统一清空本地缓存
+			log.Printf("cache: 接收失效消息失败, err: %v", err)
+			t.reset(false)
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			// 订阅连接重连之后 ID 变了，读写连接需要重新建立
+			if m.Kind == "subscribe" {
+				t.reset(true)
+			}
+		case *redis.Message:
+			t.invalidate(ctx, m.PayloadSlice)
+		}
+	}
+}
+
+// invalidate 删除本地缓存的 keys，回调在释放 fillMutex 之后才调用
+func (t *TrackingRedisCache) invalidate(ctx context.Context, keys []string) {
+	t.mutex.RLock()
+	local := t.local
+	t.mutex.RUnlock()
+	evicted := make(map[string]any, len(keys))
+	t.fillMutex.Lock()
+	atomic.AddUint64(&t.invalidations, 1)
+	for _, key := range keys {
+		// 本地没有缓存的 key 不触发回调，本实例 Set 或者 Delete 引起的失效消息也就不会重复触发
+		val, err := local.Get(ctx, key)
+		_ = local.Delete(ctx, key)
+		if err == nil {
+			evicted[key] = val
+		}
+	}
+	t.fillMutex.Unlock()
+	for _, key := range keys {
+		if val, ok := evicted[key]; ok {
+			delete(evicted, key)
+			t.notify(key, val)
+		}
+	}
+}
+
+// reset 清空本地缓存，reconnect 为 true 的时候重建读写客户端
+func (t *TrackingRedisCache) reset(reconnect bool) {
+	t.fillMutex.Lock()
+	atomic.AddUint64(&t.invalidations, 1)
+	t.fillMutex.Unlock()
+	t.mutex.Lock()
+	oldLocal, oldClient := t.local, t.client
+	t.local = NewLocalCache()
+	if reconnect {
+		t.client = t.newClient()
+	}
+	t.mutex.Unlock()
+
+	oldLocal.OnEvictedWithReason(func(key string, val any, reason EvictReason) {
+		if reason == EvictReasonDeleted {
+			t.notify(key, val)
+		}
+	})
+	_ = oldLocal.Clear(context.Background())
+	_ = oldLocal.Close()
+	if reconnect {
+		_ = oldClient.Close()
+	}
+}
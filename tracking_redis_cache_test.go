@@ -0,0 +1,120 @@
+package toycache
+
+import (
+	"context"
+	"github.com/aristletl/toycache/internal/errs"
+	"github.com/go-redis/redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTrackingRedisCache(t *testing.T) {
+	testCase := []struct {
+		name string
+		opts []TrackingRedisCacheOption
+		key  string
+	}{
+		{
+			name: "默认模式",
+			key:  "user:1",
+		},
+		{
+			name: "广播模式",
+			opts: []TrackingRedisCacheOption{WithTrackingBroadcast("user:")},
+			key:  "user:1",
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			srv := newFakeRedisServer(t)
+			other := redis.NewClient(&redis.Options{Addr: srv.addr()})
+			defer other.Close()
+
+			c, err := NewTrackingRedisCache(&redis.Options{Addr: srv.addr()}, tc.opts...)
+			require.NoError(t, err)
+			defer c.Close()
+
+			require.NoError(t, other.Set(ctx, tc.key, "v1", 0).Err())
+			val, err := c.Get(ctx, tc.key)
+			require.NoError(t, err)
+			assert.Equal(t, "v1", val)
+			// 已经缓存在本地
+			val, err = c.local.Get(ctx, tc.key)
+			require.NoError(t, err)
+			assert.Equal(t, "v1", val)
+
+			// 其它客户端修改之后，本地缓存被删除
+			require.NoError(t, other.Set(ctx, tc.key, "v2", 0).Err())
+			require.Eventually(t, func() bool {
+				_, err := c.local.Get(ctx, tc.key)
+				return err == errs.ErrKeyNotFound
+			}, time.Second, time.Millisecond)
+			val, err = c.Get(ctx, tc.key)
+			require.NoError(t, err)
+			assert.Equal(t, "v2", val)
+
+			require.NoError(t, other.Del(ctx, tc.key).Err())
+			require.Eventually(t, func() bool {
+				_, err := c.Get(ctx, tc.key)
+				return err == errs.ErrKeyNotFound
+			}, time.Second, time.Millisecond)
+		})
+	}
+}
+
+func TestTrackingRedisCache_OnEvicted(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeRedisServer(t)
+	other := redis.NewClient(&redis.Options{Addr: srv.addr()})
+	defer other.Close()
+	c, err := NewTrackingRedisCache(&redis.Options{Addr: srv.addr()})
+	require.NoError(t, err)
+	defer c.Close()
+
+	var (
+		mutex   sync.Mutex
+		evicted []string
+	)
+	c.OnEvicted(func(key string, val []byte) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		evicted = append(evicted, key+"="+string(val))
+	})
+	evictedKeys := func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]string(nil), evicted...)
+	}
+
+	// 其它客户端修改之后，回调拿到本地缓存的旧值
+	require.NoError(t, other.Set(ctx, "a", "v1", 0).Err())
+	_, err = c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.NoError(t, other.Set(ctx, "a", "v2", 0).Err())
+	require.Eventually(t, func() bool {
+		return len(evictedKeys()) == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"a=v1"}, evictedKeys())
+
+	// 本实例的 Set 不触发回调，Delete 只触发一次
+	require.NoError(t, c.Set(ctx, "b", "v1", time.Minute))
+	_, err = c.Get(ctx, "b")
+	require.NoError(t, err)
+	require.NoError(t, c.Delete(ctx, "b"))
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, []string{"a=v1", "b=v1"}, evictedKeys())
+
+	// 连接断开之后本地缓存被清空
+	_, err = c.Get(ctx, "a")
+	require.NoError(t, err)
+	srv.dropConns()
+	require.Eventually(t, func() bool {
+		return len(evictedKeys()) == 3
+	}, 3*time.Second, time.Millisecond)
+	assert.Equal(t, []string{"a=v1", "b=v1", "a=v2"}, evictedKeys())
+}
@@ -0,0 +1,150 @@
+package toycache
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+type TTLStrategyOption func(t *TTLStrategy)
+
+// TTLStrategy 装饰任意的 EliminateStrategy，行为类似于 Redis 的 volatile-ttl：
+// 优先淘汰已经过期的 key，其次是离过期时间最近的 key，
+// 没有设置过期时间的 key 交给被装饰的 EliminateStrategy 决定。
+// 过期时间来自于 Add 的 args[1]，MaxMemoryCache 写入的时候会传入
+type TTLStrategy struct {
+	EliminateStrategy
+	// horizon 大于 0 的时候，只有在 horizon 之内过期的 key 才会被优先淘汰
+	horizon time.Duration
+
+	mutex     sync.Mutex
+	deadlines ttlHeap
+	entries   map[any]*ttlEntry
+}
+
+type ttlEntry struct {
+	key      any
+	deadline time.Time
+	// index 是 ttlEntry 在 deadlines 中的下标
+	index int
+}
+
+func NewTTLStrategy(e EliminateStrategy, opts ...TTLStrategyOption) *TTLStrategy {
+	res := &TTLStrategy{
+		EliminateStrategy: e,
+		entries:           make(map[any]*ttlEntry),
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// WithTTLHorizon 只优先淘汰在 horizon 之内过期的 key，
+// 剩余时间更长的 key 交给被装饰的 EliminateStrategy 决定，避免淘汰还会存在很久的热点 key
+func WithTTLHorizon(horizon time.Duration) TTLStrategyOption {
+	return func(t *TTLStrategy) {
+		t.horizon = horizon
+	}
+}
+
+func (t *TTLStrategy) GetEliminatedKey() (any, AnyValue, bool) {
+	t.mutex.Lock()
+	var key any
+	if len(t.deadlines) > 0 {
+		top := t.deadlines[0]
+		if t.horizon <= 0 || top.deadline.Before(time.Now().Add(t.horizon)) {
+			key = top.key
+		}
+	}
+	t.mutex.Unlock()
+	if key == nil {
+		return t.EliminateStrategy.GetEliminatedKey()
+	}
+	val, _ := t.EliminateStrategy.Get(key)
+	return key, val, true
+}
+
+// Add args[1] 是 time.Time 类型的过期时间的时候更新过期时间，零值表示永不过期，
+// 没有 args[1] 的时候保留原来的过期时间
+func (t *TTLStrategy) Add(key any, args ...any) {
+	if key == nil {
+		return
+	}
+	t.EliminateStrategy.Add(key, args...)
+	if len(args) < 2 {
+		return
+	}
+	deadline, ok := args[1].(time.Time)
+	if !ok {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	e, ok := t.entries[key]
+	switch {
+	case deadline.IsZero():
+		if ok {
+			heap.Remove(&t.deadlines, e.index)
+			delete(t.entries, key)
+		}
+	case ok:
+		e.deadline = deadline
+		heap.Fix(&t.deadlines, e.index)
+	default:
+		e = &ttlEntry{key: key, deadline: deadline}
+		t.entries[key] = e
+		heap.Push(&t.deadlines, e)
+	}
+}
+
+func (t *TTLStrategy) Remove(key any) bool {
+	t.mutex.Lock()
+	if e, ok := t.entries[key]; ok {
+		heap.Remove(&t.deadlines, e.index)
+		delete(t.entries, key)
+	}
+	t.mutex.Unlock()
+	return t.EliminateStrategy.Remove(key)
+}
+
+// Admit 被装饰的 EliminateStrategy 实现了 Admitter 的时候交给它判断
+func (t *TTLStrategy) Admit(key any) bool {
+	if a, ok := t.EliminateStrategy.(Admitter); ok {
+		return a.Admit(key)
+	}
+	return true
+}
+
+// ttlHeap 实现了 heap.Interface
+type ttlHeap []*ttlEntry
+
+func (h ttlHeap) Len() int {
+	return len(h)
+}
+
+func (h ttlHeap) Less(i, j int) bool {
+	return h[i].deadline.Before(h[j].deadline)
+}
+
+func (h ttlHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *ttlHeap) Push(x any) {
+	e := x.(*ttlEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *ttlHeap) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
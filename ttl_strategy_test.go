@@ -0,0 +1,59 @@
+package toycache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTTLStrategy(t *testing.T) {
+	now := time.Now()
+	s := NewTTLStrategy(NewLRU(), WithTTLHorizon(time.Hour))
+	s.Add("a", 1, now.Add(30*time.Minute))
+	s.Add("b", 2, now.Add(-time.Second))
+	s.Add("c", 3, now.Add(time.Minute))
+	s.Add("d", 4, now.Add(2*time.Hour))
+	s.Add("e", 5, time.Time{})
+	// 没有传入过期时间的时候保留原来的过期时间
+	s.Add("b", 2)
+	// 零值表示不再过期
+	s.Add("c", 3, time.Time{})
+
+	key, val, ok := s.GetEliminatedKey()
+	assert.True(t, ok)
+	assert.Equal(t, "b", key)
+	assert.Equal(t, 2, val.Val)
+
+	// 先淘汰已经过期的，再淘汰离过期时间最近的，
+	// 超过 horizon 的 key 按照 LRU 淘汰
+	var keys []any
+	for {
+		key, _, ok = s.GetEliminatedKey()
+		if !ok {
+			break
+		}
+		s.Remove(key)
+		keys = append(keys, key)
+	}
+	assert.Equal(t, []any{"b", "a", "d", "e", "c"}, keys)
+}
+
+func TestMaxMemoryCache_TTLStrategy(t *testing.T) {
+	ctx := context.Background()
+	local := NewLocalCache()
+	defer local.Close()
+	c := NewMaxMemoryCache(10, 10, NewTTLStrategy(NewLRU()), local)
+
+	assert.NoError(t, c.Set(ctx, "a", []byte("123"), time.Hour))
+	assert.NoError(t, c.Set(ctx, "b", []byte("123"), time.Minute))
+	assert.NoError(t, c.Set(ctx, "c", []byte("123"), time.Hour))
+	_, err := c.Get(ctx, "b")
+	assert.NoError(t, err)
+	// b 最近访问过，但是最早过期
+	assert.NoError(t, c.Set(ctx, "d", []byte("123"), time.Hour))
+	_, err = local.Get(ctx, "b")
+	assert.Error(t, err)
+	_, err = local.Get(ctx, "a")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,132 @@
+package toycache
+
+import "sync"
+
+const (
+	twoQueueA1in = iota + 1
+	twoQueueA1out
+	twoQueueAm
+)
+
+// TwoQueue 是 2Q 淘汰策略。新的 key 先进入先进先出的 A1in，
+// 从 A1in 淘汰的 key 记录在幽灵队列 A1out 里，只保存 key，
+// 在 A1out 里的 key 再次写入的时候才会进入 LRU 队列 Am。
+// 只访问一次的 key 不会挤掉 Am 里的 key，所以可以抵抗扫描
+type TwoQueue struct {
+	sync.Mutex
+	// kin 是 A1in 的目标大小，kout 是 A1out 的最大长度
+	kin  int
+	kout int
+
+	a1in, a1out, am *linkedList
+	cache           map[any]*twoQueueEntry
+}
+
+type twoQueueEntry struct {
+	node *node
+	list int
+}
+
+// NewTwoQueue capacity 是预期的 key 的数量，A1in 占 25%，A1out 最多记录 50% 的 key
+func NewTwoQueue(capacity int) *TwoQueue {
+	kin, kout := capacity/4, capacity/2
+	if kin < 1 {
+		kin = 1
+	}
+	if kout < 1 {
+		kout = 1
+	}
+	return &TwoQueue{
+		kin:   kin,
+		kout:  kout,
+		a1in:  newLinkedList(),
+		a1out: newLinkedList(),
+		am:    newLinkedList(),
+		cache: make(map[any]*twoQueueEntry),
+	}
+}
+
+func (q *TwoQueue) Get(key any) (AnyValue, bool) {
+	q.Lock()
+	defer q.Unlock()
+	e, ok := q.cache[key]
+	if !ok || e.list == twoQueueA1out {
+		return AnyValue{}, false
+	}
+	return AnyValue{Val: e.node.value}, true
+}
+
+// GetEliminatedKey A1in 超过目标大小的时候淘汰 A1in 最早进入的 key，否则淘汰 Am 最久没有访问的 key
+func (q *TwoQueue) GetEliminatedKey() (any, AnyValue, bool) {
+	q.Lock()
+	defer q.Unlock()
+	var n *node
+	if q.a1in.len() > q.kin || q.am.len() == 0 {
+		n = q.a1in.back()
+	} else {
+		n = q.am.back()
+	}
+	if n == nil {
+		return nil, AnyValue{}, false
+	}
+	return n.key, AnyValue{Val: n.value}, true
+}
+
+func (q *TwoQueue) Add(key any, args ...any) {
+	if key == nil {
+		return
+	}
+	var val any
+	if len(args) != 0 {
+		val = args[0]
+	}
+
+	q.Lock()
+	defer q.Unlock()
+	e, ok := q.cache[key]
+	if !ok {
+		e = &twoQueueEntry{node: &node{key: key, value: val}, list: twoQueueA1in}
+		q.cache[key] = e
+		q.a1in.pushFront(e.node)
+		return
+	}
+
+	e.node.value = val
+	switch e.list {
+	case twoQueueA1out:
+		q.a1out.remove(e.node)
+		q.am.pushFront(e.node)
+		e.list = twoQueueAm
+	case twoQueueAm:
+		q.am.moveToFront(e.node)
+	}
+	// A1in 是先进先出的，再次访问不改变顺序
+}
+
+// Remove 从 A1in 移除的 key 会进入 A1out，key 不在 A1in、Am 里的时候返回 false
+func (q *TwoQueue) Remove(key any) bool {
+	q.Lock()
+	defer q.Unlock()
+	e, ok := q.cache[key]
+	if !ok {
+		return false
+	}
+	switch e.list {
+	case twoQueueA1in:
+		q.a1in.remove(e.node)
+		e.node.value = nil
+		q.a1out.pushFront(e.node)
+		e.list = twoQueueA1out
+		if q.a1out.len() > q.kout {
+			n := q.a1out.back()
+			q.a1out.remove(n)
+			delete(q.cache, n.key)
+		}
+	case twoQueueAm:
+		q.am.remove(e.node)
+		delete(q.cache, key)
+	default:
+		return false
+	}
+	return true
+}
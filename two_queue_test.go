@@ -0,0 +1,44 @@
+package toycache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTwoQueue(t *testing.T) {
+	q := NewTwoQueue(8)
+	_, _, ok := q.GetEliminatedKey()
+	assert.False(t, ok)
+
+	for i := 1; i <= 3; i++ {
+		q.Add(i, i)
+	}
+	// A1in 是先进先出的，再次访问不会改变淘汰顺序
+	q.Add(1, 1)
+	assert.Equal(t, []any{1, 2}, evictN(t, q, 2))
+	_, ok = q.Get(1)
+	assert.False(t, ok)
+
+	// 命中 A1out 的 key 进入 Am
+	q.Add(1, 1)
+	assert.Equal(t, twoQueueAm, q.cache[1].list)
+	val, ok := q.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, 1, val.Val)
+
+	// 扫描只会淘汰 A1in 里的 key
+	for i := 10; i < 20; i++ {
+		q.Add(i, i)
+	}
+	assert.Equal(t, []any{3, 10, 11, 12, 13, 14, 15, 16}, evictN(t, q, 8))
+	// A1out 最多记录 4 个 key
+	assert.Equal(t, 4, q.a1out.len())
+	_, ok = q.cache[3]
+	assert.False(t, ok)
+
+	// A1in 没有超过目标大小，淘汰 Am
+	evictN(t, q, 1)
+	assert.Equal(t, []any{1}, evictN(t, q, 1))
+	assert.False(t, q.Remove(1))
+	assert.False(t, q.Remove(16))
+}
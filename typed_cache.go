@@ -0,0 +1,123 @@
+package toycache
+
+import (
+	"context"
+	"fmt"
+	"github.com/aristletl/toycache/internal/errs"
+	"reflect"
+	"time"
+)
+
+// TypedCache 在 Cache 之上包了一层类型安全的 API，
+// 调用方不再需要自己对 Get 的结果做类型断言
+type TypedCache[V any] struct {
+	cache Cache
+}
+
+func NewTypedCache[V any](cache Cache) *TypedCache[V] {
+	return &TypedCache[V]{
+		cache: cache,
+	}
+}
+
+// scanner 是能够把值解析到指定类型上的 Cache，例如 RedisCache
+type scanner interface {
+	Scan(ctx context.Context, key string, val any) error
+}
+
+// Get 返回 V 类型的值，类型对不上的时候返回 errs.ErrTypeMismatch。
+// 被包装的 Cache 支持 Scan 的时候直接解析成 V，
+// 所以 V 是结构体的时候 RedisCache 需要配置 WithCodec
+func (t *TypedCache[V]) Get(ctx context.Context, key string) (V, error) {
+	var zero V
+	if s, ok := t.cache.(scanner); ok {
+		var res V
+		if err := s.Scan(ctx, key, &res); err != nil {
+			return zero, err
+		}
+		return res, nil
+	}
+	val, err := t.cache.Get(ctx, key)
+	if err != nil {
+		return zero, err
+	}
+	res, ok := convert[V](val)
+	if !ok {
+		return zero, fmt.Errorf("%w: key %s, 期望 %s, 实际 %T",
+			errs.ErrTypeMismatch, key, reflect.TypeOf((*V)(nil)).Elem(), val)
+	}
+	return res, nil
+}
+
+func (t *TypedCache[V]) Set(ctx context.Context, key string, val V, expiration time.Duration) error {
+	return t.cache.Set(ctx, key, val, expiration)
+}
+
+func (t *TypedCache[V]) Delete(ctx context.Context, key string) error {
+	return t.cache.Delete(ctx, key)
+}
+
+func (t *TypedCache[V]) OnEvicted(fn func(key string, val []byte)) {
+	t.cache.OnEvicted(fn)
+}
+
+// convert 除了直接断言之外，还兼容 string 和 []byte 之间的转换，
+// 因为不支持 Scan 的远程缓存读出来的一般都是 string
+func convert[V any](val any) (V, bool) {
+	if res, ok := val.(V); ok {
+		return res, true
+	}
+	var zero V
+	switch v := val.(type) {
+	case string:
+		res, ok := any([]byte(v)).(V)
+		return res, ok
+	case []byte:
+		res, ok := any(string(v)).(V)
+		return res, ok
+	}
+	return zero, false
+}
+
+type TypedLocalCacheOption[K comparable, V any] func(l *TypedLocalCache[K, V])
+
+// TypedLocalCache 是泛型版本的本地缓存，key 可以是任意可比较的类型。
+// 它和 LocalCache 共用同一份实现，过期清理、淘汰原因这些行为都是一样的
+type TypedLocalCache[K comparable, V any] struct {
+	localStore[K, V]
+}
+
+func NewTypedLocalCache[K comparable, V any](opts ...TypedLocalCacheOption[K, V]) *TypedLocalCache[K, V] {
+	l := &TypedLocalCache[K, V]{}
+	l.init()
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.start()
+	return l
+}
+
+// WithTypedOnEvicted 和 WithOnEvicted 一样，覆盖写不会触发回调
+func WithTypedOnEvicted[K comparable, V any](fn func(key K, val V)) TypedLocalCacheOption[K, V] {
+	return func(l *TypedLocalCache[K, V]) {
+		l.onEvicted = append(l.onEvicted, func(key K, val V, reason EvictReason) {
+			if reason != EvictReasonReplaced {
+				fn(key, val)
+			}
+		})
+	}
+}
+
+// WithTypedOnEvictedReason 和 WithOnEvictedReason 一样
+func WithTypedOnEvictedReason[K comparable, V any](fn func(key K, val V, reason EvictReason)) TypedLocalCacheOption[K, V] {
+	return func(l *TypedLocalCache[K, V]) {
+		l.onEvicted = append(l.onEvicted, fn)
+	}
+}
+
+// WithTypedSweepInterval 和 WithSweepInterval 一样
+func WithTypedSweepInterval[K comparable, V any](interval time.Duration) TypedLocalCacheOption[K, V] {
+	return func(l *TypedLocalCache[K, V]) {
+		l.sweepInterval = interval
+	}
+}
@@ -0,0 +1,171 @@
+package toycache
+
+import (
+	"context"
+	"github.com/aristletl/toycache/internal/errs"
+	"github.com/aristletl/toycache/mocks"
+	"github.com/go-redis/redis/v9"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTypedCache_Get(t *testing.T) {
+	testCase := []struct {
+		name    string
+		cache   func() Cache
+		key     string
+		wantVal string
+		wantErr error
+	}{
+		{
+			name: "类型匹配",
+			cache: func() Cache {
+				c := NewLocalCache()
+				_ = c.Set(context.Background(), "key", "value", time.Minute)
+				return c
+			},
+			key:     "key",
+			wantVal: "value",
+		},
+		{
+			name: "[]byte 转 string",
+			cache: func() Cache {
+				c := NewLocalCache()
+				_ = c.Set(context.Background(), "key", []byte("value"), time.Minute)
+				return c
+			},
+			key:     "key",
+			wantVal: "value",
+		},
+		{
+			name: "类型不匹配",
+			cache: func() Cache {
+				c := NewLocalCache()
+				_ = c.Set(context.Background(), "key", 123, time.Minute)
+				return c
+			},
+			key:     "key",
+			wantErr: errs.ErrTypeMismatch,
+		},
+		{
+			name: "key 不存在",
+			cache: func() Cache {
+				return NewLocalCache()
+			},
+			key:     "key",
+			wantErr: errs.ErrKeyNotFound,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewTypedCache[string](tc.cache())
+			val, err := c.Get(context.Background(), tc.key)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantVal, val)
+		})
+	}
+}
+
+func TestTypedCache_RedisCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cmd := mocks.NewMockCmdable(ctrl)
+	ok := redis.NewStatusCmd(nil)
+	ok.SetVal("OK")
+	cmd.EXPECT().Set(gomock.Any(), "key", []byte(`{"Name":"Tom"}`), time.Minute).Return(ok)
+	hit := redis.NewStringCmd(nil)
+	hit.SetVal(`{"Name":"Tom"}`)
+	cmd.EXPECT().Get(gomock.Any(), "key").Return(hit)
+	miss := redis.NewStringCmd(nil)
+	miss.SetErr(redis.Nil)
+	cmd.EXPECT().Get(gomock.Any(), "missing").Return(miss)
+	mismatch := redis.NewStringCmd(nil)
+	mismatch.SetVal(`"abc"`)
+	cmd.EXPECT().Get(gomock.Any(), "mismatch").Return(mismatch)
+	broken := redis.NewStringCmd(nil)
+	broken.SetErr(context.DeadlineExceeded)
+	cmd.EXPECT().Get(gomock.Any(), "broken").Return(broken)
+
+	c := NewTypedCache[testUser](NewRedisCache(cmd, WithCodec(JSONCodec{})))
+	ctx := context.Background()
+	assert.NoError(t, c.Set(ctx, "key", testUser{Name: "Tom"}, time.Minute))
+	val, err := c.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, testUser{Name: "Tom"}, val)
+	_, err = c.Get(ctx, "missing")
+	assert.Equal(t, errs.ErrKeyNotFound, err)
+	_, err = c.Get(ctx, "mismatch")
+	assert.ErrorIs(t, err, errs.ErrTypeMismatch)
+	// 网络错误原样返回
+	_, err = c.Get(ctx, "broken")
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestTypedCache_RedisCacheWithoutCodec(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cmd := mocks.NewMockCmdable(ctrl)
+	hit := redis.NewStringCmd(nil)
+	hit.SetVal("123")
+	cmd.EXPECT().Get(gomock.Any(), "key").Return(hit)
+	mismatch := redis.NewStringCmd(nil)
+	mismatch.SetVal("abc")
+	cmd.EXPECT().Get(gomock.Any(), "mismatch").Return(mismatch)
+
+	// 没有 codec 的时候使用 redis 客户端自带的解析
+	c := NewTypedCache[int](NewRedisCache(cmd))
+	val, err := c.Get(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.Equal(t, 123, val)
+	_, err = c.Get(context.Background(), "mismatch")
+	assert.ErrorIs(t, err, errs.ErrTypeMismatch)
+}
+
+func TestTypedLocalCache(t *testing.T) {
+	type user struct {
+		Name string
+	}
+	var evicted []int
+	c := NewTypedLocalCache[int, user](WithTypedOnEvicted(func(key int, val user) {
+		evicted = append(evicted, key)
+	}))
+	defer c.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, c.Set(ctx, 1, user{Name: "Tom"}, time.Minute))
+	val, err := c.Get(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, user{Name: "Tom"}, val)
+
+	assert.NoError(t, c.Set(ctx, 2, user{Name: "Jerry"}, time.Millisecond))
+	time.Sleep(2 * time.Millisecond)
+	_, err = c.Get(ctx, 2)
+	assert.Equal(t, errs.ErrKeyNotFound, err)
+
+	assert.NoError(t, c.Delete(ctx, 1))
+	_, err = c.Get(ctx, 1)
+	assert.Equal(t, errs.ErrKeyNotFound, err)
+	assert.Equal(t, []int{2, 1}, evicted)
+}
+
+func TestTypedLocalCache_Reason(t *testing.T) {
+	var reasons []EvictReason
+	c := NewTypedLocalCache[int, string](
+		WithTypedSweepInterval[int, string](10*time.Millisecond),
+		WithTypedOnEvictedReason(func(key int, val string, reason EvictReason) {
+			reasons = append(reasons, reason)
+		}))
+	defer c.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, c.Set(ctx, 1, "a", time.Minute))
+	assert.NoError(t, c.Set(ctx, 1, "b", time.Millisecond))
+	// 过期的数据由后台清理
+	assert.Eventually(t, func() bool {
+		c.RLock()
+		defer c.RUnlock()
+		return len(c.data) == 0 && len(c.expiries) == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []EvictReason{EvictReasonReplaced, EvictReasonExpired}, reasons)
+}
@@ -18,10 +18,41 @@ type Cache interface {
 	OnEvicted(func(key string, val []byte))
 }
 
+// EvictReason 描述 key 离开缓存的原因
+type EvictReason uint8
+
+const (
+	// EvictReasonExpired 过期
+	EvictReasonExpired EvictReason = iota + 1
+	// EvictReasonDeleted 被调用方删除
+	EvictReasonDeleted
+	// EvictReasonReplaced 被新的值覆盖，key 本身还在缓存里
+	EvictReasonReplaced
+	// EvictReasonCapacity 容量不足被淘汰
+	EvictReasonCapacity
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictReasonExpired:
+		return "expired"
+	case EvictReasonDeleted:
+		return "deleted"
+	case EvictReasonReplaced:
+		return "replaced"
+	case EvictReasonCapacity:
+		return "capacity"
+	default:
+		return "unknown"
+	}
+}
+
 type EliminateStrategy interface {
 	// GetOldest 获取应该被淘汰的key
 	GetEliminatedKey() (any, AnyValue, bool)
 	// Add 添加一个key
+	// MaxMemoryCache 调用的时候 args[0] 是值的大小，
+	// 写入的时候 args[1] 是 time.Time 类型的过期时间，零值表示永不过期
 	Add(key any, args ...any)
 
 	Remove(key any) bool
@@ -29,6 +60,14 @@ type EliminateStrategy interface {
 	Get(key any) (AnyValue, bool)
 }
 
+// Admitter 是带有准入策略的 EliminateStrategy。
+// 容量不足的时候，MaxMemoryCache 会先询问新的 key 是否值得写入，
+// 不值得的话直接放弃写入，而不是淘汰已有的热点数据
+type Admitter interface {
+	// Admit 会记录一次 key 的访问，返回 false 表示应该放弃写入
+	Admit(key any) bool
+}
+
 type AnyValue struct {
 	Val any
 	Err error
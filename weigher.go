@@ -0,0 +1,118 @@
+package toycache
+
+import "reflect"
+
+// Weigher 计算 key 和 val 占用的成本，MaxMemoryCache 按照成本控制容量，
+// 成本的单位由使用者决定，可以是字节数，也可以是条目数之类的
+type Weigher func(key string, val any) int64
+
+// DefaultWeigher []byte 和 string 的成本是它们的长度，其它类型使用 EstimateSize 估算
+func DefaultWeigher(key string, val any) int64 {
+	switch v := val.(type) {
+	case []byte:
+		return int64(len(v))
+	case string:
+		return int64(len(v))
+	default:
+		return EstimateSize(val)
+	}
+}
+
+// EstimateSize 通过反射估算 val 占用的内存大小，包括它通过指针、切片、map 等引用的内存。
+// 同一块内存只会计算一次，所以带有环的结构也可以估算。
+// map 内部的桶、对齐之类的开销没有计算在内，chan 和 func 只计算它们本身的大小，
+// 所以结果只是一个近似值
+func EstimateSize(val any) int64 {
+	if val == nil {
+		return 0
+	}
+	v := reflect.ValueOf(val)
+	return int64(v.Type().Size()) + indirectSize(v, make(map[uintptr]struct{}))
+}
+
+// indirectSize 返回 v 引用的、不在 v 本身里面的内存大小
+func indirectSize(v reflect.Value, seen map[uintptr]struct{}) int64 {
+	switch v.Kind() {
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Slice:
+		if v.IsNil() || visited(v.Pointer(), seen) {
+			return 0
+		}
+		res := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		if hasIndirect(v.Type().Elem()) {
+			for i := 0; i < v.Len(); i++ {
+				res += indirectSize(v.Index(i), seen)
+			}
+		}
+		return res
+	case reflect.Array:
+		var res int64
+		if hasIndirect(v.Type().Elem()) {
+			for i := 0; i < v.Len(); i++ {
+				res += indirectSize(v.Index(i), seen)
+			}
+		}
+		return res
+	case reflect.Ptr:
+		if v.IsNil() || visited(v.Pointer(), seen) {
+			return 0
+		}
+		return int64(v.Type().Elem().Size()) + indirectSize(v.Elem(), seen)
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		e := v.Elem()
+		return int64(e.Type().Size()) + indirectSize(e, seen)
+	case reflect.Map:
+		if v.IsNil() || visited(v.Pointer(), seen) {
+			return 0
+		}
+		t := v.Type()
+		res := int64(v.Len()) * int64(t.Key().Size()+t.Elem().Size())
+		if hasIndirect(t.Key()) || hasIndirect(t.Elem()) {
+			iter := v.MapRange()
+			for iter.Next() {
+				res += indirectSize(iter.Key(), seen) + indirectSize(iter.Value(), seen)
+			}
+		}
+		return res
+	case reflect.Struct:
+		var res int64
+		for i := 0; i < v.NumField(); i++ {
+			res += indirectSize(v.Field(i), seen)
+		}
+		return res
+	default:
+		return 0
+	}
+}
+
+// visited 记录已经计算过的内存，返回是否计算过
+func visited(p uintptr, seen map[uintptr]struct{}) bool {
+	if _, ok := seen[p]; ok {
+		return true
+	}
+	seen[p] = struct{}{}
+	return false
+}
+
+// hasIndirect 判断 t 类型的值是否可能引用其它内存，不会的话就不需要逐个元素计算
+func hasIndirect(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Ptr, reflect.Interface, reflect.Map:
+		return true
+	case reflect.Array:
+		return hasIndirect(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if hasIndirect(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
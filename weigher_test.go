@@ -0,0 +1,82 @@
+package toycache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEstimateSize(t *testing.T) {
+	type user struct {
+		Name string
+		Age  int64
+		Tags []string
+	}
+	type list struct {
+		val  int64
+		next *list
+	}
+	cycle := &list{val: 1}
+	cycle.next = cycle
+
+	testCase := []struct {
+		name     string
+		val      any
+		wantSize int64
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name:     "int64",
+			val:      int64(1),
+			wantSize: 8,
+		},
+		{
+			name:     "string",
+			val:      "hello",
+			wantSize: 16 + 5,
+		},
+		{
+			name:     "slice",
+			val:      make([]int32, 2, 4),
+			wantSize: 24 + 4*4,
+		},
+		{
+			name:     "struct",
+			val:      user{Name: "Tom", Age: 18, Tags: []string{"a", "bc"}},
+			wantSize: 16 + 8 + 24 + 3 + 2*16 + 1 + 2,
+		},
+		{
+			name:     "pointer",
+			val:      &user{Name: "Tom"},
+			wantSize: 8 + 16 + 8 + 24 + 3,
+		},
+		{
+			name:     "map",
+			val:      map[string]int64{"a": 1, "bc": 2},
+			wantSize: 8 + 2*(16+8) + 1 + 2,
+		},
+		{
+			name:     "interface",
+			val:      []any{int64(1), "a"},
+			wantSize: 24 + 2*16 + 8 + 16 + 1,
+		},
+		{
+			name:     "环",
+			val:      cycle,
+			wantSize: 8 + 16,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantSize, EstimateSize(tc.val))
+		})
+	}
+}
+
+func TestDefaultWeigher(t *testing.T) {
+	assert.Equal(t, int64(3), DefaultWeigher("key", []byte("abc")))
+	assert.Equal(t, int64(3), DefaultWeigher("key", "abc"))
+	assert.Equal(t, int64(8), DefaultWeigher("key", int64(1)))
+}
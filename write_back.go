@@ -0,0 +1,176 @@
+package toycache
+
+import (
+	"context"
+	"github.com/aristletl/toycache/internal/errs"
+	"log"
+	"sync"
+	"time"
+)
+
+// BatchStoreFunc 把一批数据写入数据源
+type BatchStoreFunc func(ctx context.Context, entries map[string]any) error
+
+type WriteBackCacheOption func(w *WriteBackCache)
+
+// WriteBackCache 只写缓存，同时把 key 标记为脏数据，
+// 后台按照时间间隔或者脏数据的数量批量写回数据源。
+// 写回失败的数据会留在脏数据里，等待下一次写回
+type WriteBackCache struct {
+	Cache
+	storeFunc BatchStoreFunc
+	interval  time.Duration
+	batchSize int
+
+	mutex sync.Mutex
+	dirty map[string]any
+	// closeMutex 保证 Close 开始写回之后不会再有新的脏数据
+	closeMutex sync.RWMutex
+	closed     bool
+	// flushMutex 保证同一时刻只有一个 Flush 在执行
+	flushMutex sync.Mutex
+
+	signal    chan struct{}
+	close     chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+	closeErr  error
+}
+
+func NewWriteBackCache(cache Cache, storeFunc BatchStoreFunc, opts ...WriteBackCacheOption) *WriteBackCache {
+	res := &WriteBackCache{
+		Cache:     cache,
+		storeFunc: storeFunc,
+		interval:  time.Second,
+		batchSize: 100,
+		dirty:     make(map[string]any),
+		signal:    make(chan struct{}, 1),
+		close:     make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	if res.interval <= 0 {
+		res.interval = time.Second
+	}
+	if res.batchSize <= 0 {
+		res.batchSize = 100
+	}
+
+	go res.loop()
+	return res
+}
+
+// WithWriteBackInterval 设置定时写回的时间间隔，小于等于 0 的时候使用默认的一秒
+func WithWriteBackInterval(interval time.Duration) WriteBackCacheOption {
+	return func(w *WriteBackCache) {
+		w.interval = interval
+	}
+}
+
+// WithWriteBackBatchSize 设置每一批写回的数量，
+// 脏数据达到这个数量的时候也会立刻触发写回，小于等于 0 的时候使用默认的 100
+func WithWriteBackBatchSize(size int) WriteBackCacheOption {
+	return func(w *WriteBackCache) {
+		w.batchSize = size
+	}
+}
+
+// Set 在 Close 之后返回 errs.ErrCacheClosed
+func (w *WriteBackCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	w.closeMutex.RLock()
+	defer w.closeMutex.RUnlock()
+	if w.closed {
+		return errs.ErrCacheClosed
+	}
+	if err := w.Cache.Set(ctx, key, val, expiration); err != nil {
+		return err
+	}
+	w.mutex.Lock()
+	w.dirty[key] = val
+	full := len(w.dirty) >= w.batchSize
+	w.mutex.Unlock()
+	if full {
+		select {
+		case w.signal <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Flush 立刻把所有的脏数据写回数据源
+func (w *WriteBackCache) Flush(ctx context.Context) error {
+	w.flushMutex.Lock()
+	defer w.flushMutex.Unlock()
+
+	w.mutex.Lock()
+	entries := w.dirty
+	w.dirty = make(map[string]any, len(entries))
+	w.mutex.Unlock()
+
+	batch := make(map[string]any, w.batchSize)
+	for key, val := range entries {
+		batch[key] = val
+		if len(batch) < w.batchSize {
+			continue
+		}
+		if err := w.storeFunc(ctx, batch); err != nil {
+			w.restore(entries)
+			return err
+		}
+		for k := range batch {
+			delete(entries, k)
+		}
+		batch = make(map[string]any, w.batchSize)
+	}
+	if len(batch) > 0 {
+		if err := w.storeFunc(ctx, batch); err != nil {
+			w.restore(entries)
+			return err
+		}
+	}
+	return nil
+}
+
+// restore 把写回失败的数据放回去，已经有更新的值的 key 不需要覆盖
+func (w *WriteBackCache) restore(entries map[string]any) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for key, val := range entries {
+		if _, ok := w.dirty[key]; !ok {
+			w.dirty[key] = val
+		}
+	}
+}
+
+// Close 停止后台写回，并且把剩下的脏数据全部写回
+func (w *WriteBackCache) Close() error {
+	w.closeOnce.Do(func() {
+		w.closeMutex.Lock()
+		w.closed = true
+		w.closeMutex.Unlock()
+		close(w.close)
+		<-w.done
+		w.closeErr = w.Flush(context.Background())
+	})
+	return w.closeErr
+}
+
+func (w *WriteBackCache) loop() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.signal:
+		case <-w.close:
+			return
+		}
+		if err := w.Flush(context.Background()); err != nil {
+			log.Printf("cache: 写回数据失败, err: %v", err)
+		}
+	}
+}
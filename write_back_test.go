@@ -0,0 +1,160 @@
+package toycache
+
+import (
+	"context"
+	"errors"
+	"github.com/aristletl/toycache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordStore 记录写回数据源的数据，fail 返回 true 的时候写回失败
+type recordStore struct {
+	mutex  sync.Mutex
+	calls  int
+	stored map[string]any
+	fail   func(call int, entries map[string]any) bool
+}
+
+func (r *recordStore) store(ctx context.Context, entries map[string]any) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls++
+	if r.fail != nil && r.fail(r.calls, entries) {
+		return errors.New("db down")
+	}
+	if r.stored == nil {
+		r.stored = make(map[string]any)
+	}
+	for key, val := range entries {
+		r.stored[key] = val
+	}
+	return nil
+}
+
+func (r *recordStore) get(key string) (any, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	val, ok := r.stored[key]
+	return val, ok
+}
+
+func (r *recordStore) len() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.stored)
+}
+
+func TestWriteBackCache_Interval(t *testing.T) {
+	local := NewLocalCache()
+	defer local.Close()
+	store := &recordStore{}
+	c := NewWriteBackCache(local, store.store, WithWriteBackInterval(10*time.Millisecond))
+	defer c.Close()
+
+	require.NoError(t, c.Set(context.Background(), "key", "value", time.Minute))
+	// 缓存是立刻写入的
+	val, err := local.Get(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", val)
+	assert.Eventually(t, func() bool {
+		val, ok := store.get("key")
+		return ok && val == "value"
+	}, time.Second, time.Millisecond)
+}
+
+func TestWriteBackCache_BatchSize(t *testing.T) {
+	local := NewLocalCache()
+	defer local.Close()
+	store := &recordStore{}
+	c := NewWriteBackCache(local, store.store,
+		WithWriteBackInterval(time.Hour), WithWriteBackBatchSize(2))
+	defer c.Close()
+
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "a", 1, time.Minute))
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 0, store.len())
+	// 脏数据达到批量大小，立刻写回
+	require.NoError(t, c.Set(ctx, "b", 2, time.Minute))
+	assert.Eventually(t, func() bool {
+		return store.len() == 2
+	}, time.Second, time.Millisecond)
+}
+
+func TestWriteBackCache_Restore(t *testing.T) {
+	local := NewLocalCache()
+	defer local.Close()
+	var (
+		c       *WriteBackCache
+		updated string
+	)
+	store := &recordStore{
+		// 第二批写回失败，失败之前这一批里的某个 key 被更新了
+		fail: func(call int, entries map[string]any) bool {
+			if call != 2 {
+				return false
+			}
+			for key := range entries {
+				updated = key
+			}
+			c.mutex.Lock()
+			c.dirty[updated] = "new"
+			c.mutex.Unlock()
+			return true
+		},
+	}
+	c = NewWriteBackCache(local, store.store,
+		WithWriteBackInterval(time.Hour), WithWriteBackBatchSize(2))
+	defer c.Close()
+	// 直接写入脏数据，避免触发后台写回
+	c.mutex.Lock()
+	c.dirty = map[string]any{"a": 1, "b": 2, "c": 3, "d": 4}
+	c.mutex.Unlock()
+
+	ctx := context.Background()
+	assert.Error(t, c.Flush(ctx))
+	assert.Equal(t, 2, store.len())
+	c.mutex.Lock()
+	assert.Equal(t, 2, len(c.dirty))
+	c.mutex.Unlock()
+
+	require.NoError(t, c.Flush(ctx))
+	assert.Equal(t, 4, store.len())
+	// 写回失败的数据不会覆盖更新的值
+	val, _ := store.get(updated)
+	assert.Equal(t, "new", val)
+}
+
+func TestWriteBackCache_Close(t *testing.T) {
+	local := NewLocalCache()
+	defer local.Close()
+	store := &recordStore{}
+	c := NewWriteBackCache(local, store.store, WithWriteBackInterval(time.Hour))
+
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "a", 1, time.Minute))
+	require.NoError(t, c.Set(ctx, "b", 2, time.Minute))
+	// Close 的时候把剩下的脏数据全部写回
+	require.NoError(t, c.Close())
+	assert.Equal(t, 2, store.len())
+
+	assert.Equal(t, errs.ErrCacheClosed, c.Set(ctx, "c", 3, time.Minute))
+	_, err := local.Get(ctx, "c")
+	assert.Equal(t, errs.ErrKeyNotFound, err)
+	assert.NoError(t, c.Close())
+}
+
+func TestWriteBackCache_InvalidOptions(t *testing.T) {
+	local := NewLocalCache()
+	defer local.Close()
+	store := &recordStore{}
+	c := NewWriteBackCache(local, store.store,
+		WithWriteBackInterval(0), WithWriteBackBatchSize(-1))
+	defer c.Close()
+	assert.Equal(t, time.Second, c.interval)
+	assert.Equal(t, 100, c.batchSize)
+}
@@ -0,0 +1,30 @@
+package toycache
+
+import (
+	"context"
+	"time"
+)
+
+// StoreFunc 把数据写入数据源
+type StoreFunc func(ctx context.Context, key string, val any) error
+
+// WriteThroughCache 先写数据源，成功之后再写缓存，
+// Set 返回的时候数据一定已经落到数据源里了
+type WriteThroughCache struct {
+	Cache
+	storeFunc StoreFunc
+}
+
+func NewWriteThroughCache(cache Cache, storeFunc StoreFunc) *WriteThroughCache {
+	return &WriteThroughCache{
+		Cache:     cache,
+		storeFunc: storeFunc,
+	}
+}
+
+func (w *WriteThroughCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	if err := w.storeFunc(ctx, key, val); err != nil {
+		return err
+	}
+	return w.Cache.Set(ctx, key, val, expiration)
+}
@@ -0,0 +1,47 @@
+package toycache
+
+import (
+	"context"
+	"errors"
+	"github.com/aristletl/toycache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWriteThroughCache_Set(t *testing.T) {
+	errDB := errors.New("db down")
+	testCase := []struct {
+		name      string
+		storeErr  error
+		wantErr   error
+		wantCache error
+	}{
+		{
+			name: "写入成功",
+		},
+		{
+			name:      "数据源写入失败不写缓存",
+			storeErr:  errDB,
+			wantErr:   errDB,
+			wantCache: errs.ErrKeyNotFound,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			local := NewLocalCache()
+			defer local.Close()
+			var stored []string
+			c := NewWriteThroughCache(local, func(ctx context.Context, key string, val any) error {
+				stored = append(stored, key)
+				return tc.storeErr
+			})
+			assert.Equal(t, tc.wantErr, c.Set(ctx, "key", "value", time.Minute))
+			assert.Equal(t, []string{"key"}, stored)
+			_, err := local.Get(ctx, "key")
+			assert.Equal(t, tc.wantCache, err)
+		})
+	}
+}